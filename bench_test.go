@@ -0,0 +1,315 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The benchmarks run each stage of the pipeline over the same corpora and
+// report throughput in MB/s of YAML input. To check a change for
+// regressions, run them before and after it,
+//
+//	go test -run '^$' -bench . -count 10 > old.txt
+//	go test -run '^$' -bench . -count 10 > new.txt
+//
+// and compare the two with benchstat old.txt new.txt.
+
+// benchConfig is a typical configuration document: nested maps, a list of
+// maps, and a few flow collections and block scalars.
+const benchConfig = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  labels: {app: web, tier: frontend}
+  annotations:
+    description: >
+      The public web frontend, which serves the
+      static site and proxies the API.
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          image: "registry.example.com/web:1.4.2"
+          args: [--port, "8080", --verbose]
+          ports:
+            - containerPort: 8080
+              protocol: TCP
+          env:
+            - name: LOG_LEVEL
+              value: info
+            - name: CACHE_SIZE
+              value: '256'
+          resources:
+            limits: {cpu: 500m, memory: 128Mi}
+        - name: sidecar
+          image: registry.example.com/proxy:2.0
+          command:
+            - /bin/sh
+            - -c
+            - |
+              exec proxy --upstream localhost:8080 \
+                --listen :9090
+`
+
+// discardHandler ignores every event, to measure the parser alone.
+type discardHandler struct{}
+
+func (discardHandler) DocumentStart(mark Mark)                                   {}
+func (discardHandler) DocumentEnd()                                              {}
+func (discardHandler) Null(mark Mark, anchor Anchor)                             {}
+func (discardHandler) Alias(mark Mark, anchor Anchor)                            {}
+func (discardHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {}
+func (discardHandler) SequenceStart(mark Mark, tag string, anchor Anchor)        {}
+func (discardHandler) SequenceEnd()                                              {}
+func (discardHandler) MapStart(mark Mark, tag string, anchor Anchor)             {}
+func (discardHandler) MapEnd()                                                   {}
+
+func parseAll(b *testing.B, parser *Parser) {
+	for {
+		ok, err := parser.HandleNextDocument(discardHandler{})
+		if err != nil {
+			b.Fatal(err)
+		} else if !ok {
+			return
+		}
+	}
+}
+
+// BenchmarkParserNew parses a document with a new Parser each time.
+func BenchmarkParserNew(b *testing.B) {
+	src := []byte(benchConfig)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseAll(b, NewParser(bytes.NewReader(src)))
+	}
+}
+
+// BenchmarkParserReuse parses a document with one Parser, reset with Load.
+func BenchmarkParserReuse(b *testing.B) {
+	src := []byte(benchConfig)
+	reader := bytes.NewReader(src)
+	parser := NewParser(reader)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(src)
+		parser.Load(reader)
+		parseAll(b, parser)
+	}
+}
+
+// BenchmarkParserStream parses a stream of many documents, to show the
+// allocations per document once the parser is warmed up.
+func BenchmarkParserStream(b *testing.B) {
+	src := []byte(strings.Repeat("---\n"+benchConfig, 100))
+	reader := bytes.NewReader(src)
+	parser := NewParser(reader)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(src)
+		parser.Load(reader)
+		parseAll(b, parser)
+	}
+	b.ReportMetric(float64(testing.AllocsPerRun(1, func() {
+		reader.Reset(src)
+		parser.Load(reader)
+		parseAll(b, parser)
+	}))/100, "allocs/doc")
+}
+
+type benchCorpus struct {
+	name string
+	data []byte
+}
+
+// benchCorpora returns the inputs the benchmarks run over, made once.
+var benchCorpora = sync.OnceValue(func() []benchCorpus {
+	return []benchCorpus{
+		{"SmallConfig", []byte(benchConfig)},
+		{"FlatList", benchFlatList(20000)},
+		{"NestedManifest", benchNestedManifest(200, 12)},
+		{"BlockScalars", benchBlockScalars(100, 200)},
+		{"Aliases", benchAliases(2000)},
+		{"JSONArray", benchJSONArray(1 << 20)},
+	}
+})
+
+// benchFlatList is a long sequence of small flat maps, like a log export.
+func benchFlatList(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "- id: %d\n  level: info\n  message: \"request %d served\"\n  took: %d.5\n", i, i, i%300)
+	}
+	return b.Bytes()
+}
+
+// benchNestedManifest is a stream of documents nested depth levels deep,
+// alternating maps and sequences.
+func benchNestedManifest(docs int, depth int) []byte {
+	var b bytes.Buffer
+	for d := 0; d < docs; d++ {
+		b.WriteString("---\n")
+		for level := 0; level < depth; level++ {
+			indent := strings.Repeat("  ", level)
+			if level%2 == 0 {
+				fmt.Fprintf(&b, "%vname: level%d\n%vflags: [a, b, c]\n%vchildren:\n", indent, level, indent, indent)
+			} else {
+				fmt.Fprintf(&b, "%v- value: %d\n%v  ", indent, level, indent)
+				b.WriteString("items:\n")
+				indent += "  "
+				fmt.Fprintf(&b, "%v  - x\n%v  - y\n", indent, indent)
+			}
+		}
+		fmt.Fprintf(&b, "%vleaf: true\n", strings.Repeat("  ", depth))
+	}
+	return b.Bytes()
+}
+
+// benchBlockScalars is a map of long literal and folded block scalars.
+func benchBlockScalars(n int, lines int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		indicator := "|"
+		if i%2 == 1 {
+			indicator = ">"
+		}
+		fmt.Fprintf(&b, "script%d: %v\n", i, indicator)
+		for line := 0; line < lines; line++ {
+			fmt.Fprintf(&b, "  line %d of a long block of text, with some: punctuation # and symbols\n", line)
+		}
+	}
+	return b.Bytes()
+}
+
+// benchJSONArray is a JSON array of small objects on a single line, about
+// size bytes long. The whole of it is one flow collection, which the
+// scanner holds in its token queue until the collection ends.
+func benchJSONArray(size int) []byte {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i := 0; b.Len() < size; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"id":%d,"name":"item %d","tags":["a","b"],"ok":true}`, i, i)
+	}
+	b.WriteByte(']')
+	return b.Bytes()
+}
+
+// benchAliases defines a few anchored templates and refers to them over
+// and over.
+func benchAliases(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("templates:\n")
+	for t := 0; t < 10; t++ {
+		fmt.Fprintf(&b, "  t%d: &t%d {image: app:%d, ports: [80, 443], env: {MODE: prod}}\n", t, t, t)
+	}
+	b.WriteString("services:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "  - name: svc%d\n    spec: *t%d\n    labels: *t%d\n", i, i%10, (i+3)%10)
+	}
+	return b.Bytes()
+}
+
+// benchEachCorpus runs a benchmark on each corpus.
+func benchEachCorpus(b *testing.B, run func(b *testing.B, data []byte)) {
+	for _, corpus := range benchCorpora() {
+		b.Run(corpus.name, func(b *testing.B) {
+			b.SetBytes(int64(len(corpus.data)))
+			b.ReportAllocs()
+			run(b, corpus.data)
+		})
+	}
+}
+
+// BenchmarkScan reads the tokens of each corpus.
+func BenchmarkScan(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, data []byte) {
+		for i := 0; i < b.N; i++ {
+			tokens := NewTokenStream(bytes.NewReader(data))
+			for tokens.Next() {
+			}
+			if err := tokens.Err(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkParse parses each corpus into events, with one Parser.
+func BenchmarkParse(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, data []byte) {
+		reader := bytes.NewReader(data)
+		parser := NewParser(reader)
+		for i := 0; i < b.N; i++ {
+			reader.Reset(data)
+			parser.Load(reader)
+			parseAll(b, parser)
+		}
+	})
+}
+
+// BenchmarkDecode builds the node trees of each corpus.
+func BenchmarkDecode(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, data []byte) {
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadAll(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDecodeParallel builds the node trees of each corpus with a
+// ParallelDecoder.
+func BenchmarkDecodeParallel(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, data []byte) {
+		for i := 0; i < b.N; i++ {
+			decoder := NewParallelDecoder(bytes.NewReader(data))
+			for {
+				_, err := decoder.Decode()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkEmit writes out the node trees of each corpus.
+func BenchmarkEmit(b *testing.B) {
+	benchEachCorpus(b, func(b *testing.B, data []byte) {
+		docs, err := LoadAll(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			emitter := NewEmitter(io.Discard)
+			for _, doc := range docs {
+				if err := emitter.Emit(doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package yaml
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// canonicalDoc writes n as a document in the canonical form of the YAML
+// spec: every node tagged with its resolved tag, every scalar
+// double-quoted, and every collection in flow style with one entry per line
+// and explicit keys:
+//
+//	%YAML 1.2
+//	---
+//	!!map {
+//	  ? !!str "name"
+//	  : !!str "web",
+//	  ? !!str "ports"
+//	  : !!seq [
+//	    !!int "80",
+//	  ],
+//	}
+//	...
+//
+// Scalars are spelled one way for their tag (0x10 as "16", ~ as ""), map
+// entries are sorted by key, and only nodes that occur more than once get an
+// anchor, named a1, a2, ... in order of appearance, so the output doesn't
+// depend on how the input wrote any of them.
+func (d *docEmitter) canonicalDoc(n *Node) {
+	d.sortKeys = true
+	d.refs = make(map[*Node]int)
+	countRefs(n, d.refs)
+
+	d.WriteString("%YAML 1.2\n---\n")
+	d.canonical(n, 0)
+	d.WriteString("\n...\n")
+}
+
+// countRefs counts the occurrences of each node below n, visiting shared
+// nodes once.
+func countRefs(n *Node, refs map[*Node]int) {
+	refs[n]++
+	if refs[n] > 1 {
+		return
+	}
+	for _, item := range n.Children {
+		countRefs(item, refs)
+	}
+	for _, pair := range n.Pairs {
+		countRefs(pair.Key, refs)
+		countRefs(pair.Value, refs)
+	}
+}
+
+func (d *docEmitter) canonical(n *Node, indent int) {
+	if name, ok := d.names[n]; ok {
+		d.WriteString("*" + name)
+		return
+	}
+
+	if d.refs[n] > 1 {
+		name := "a" + strconv.Itoa(len(d.names)+1)
+		d.names[n] = name
+		d.WriteString("&" + name + " ")
+	}
+	d.WriteString(canonicalTag(n.ResolvedTag()) + " ")
+
+	pad := strings.Repeat(" ", indent+2)
+	switch n.Type {
+	case NODE_SEQUENCE:
+		if len(n.Children) == 0 {
+			d.WriteString("[]")
+			return
+		}
+
+		d.WriteString("[\n")
+		for _, item := range n.Children {
+			d.WriteString(pad)
+			d.canonical(item, indent+2)
+			d.WriteString(",\n")
+		}
+		d.WriteString(pad[2:] + "]")
+	case NODE_MAP:
+		if len(n.Pairs) == 0 {
+			d.WriteString("{}")
+			return
+		}
+
+		d.WriteString("{\n")
+		for _, pair := range d.pairs(n) {
+			d.WriteString(pad + "? ")
+			d.canonical(pair.Key, indent+2)
+			d.WriteString("\n" + pad + ": ")
+			d.canonical(pair.Value, indent+2)
+			d.WriteString(",\n")
+		}
+		d.WriteString(pad[2:] + "}")
+	default:
+		d.WriteString(doubleQuote(canonicalValue(n.ResolvedTag(), n.Value)))
+	}
+}
+
+// canonicalValue spells the value of a scalar with a core schema tag the
+// one way the canonical form writes it; see normalizeScalar.
+func canonicalValue(tag string, value string) string {
+	value = normalizeScalar(tag, value)
+	if tag == TAG_FLOAT {
+		switch value {
+		case "+Inf":
+			return ".inf"
+		case "-Inf":
+			return "-.inf"
+		case "NaN":
+			return ".nan"
+		}
+	}
+	return value
+}
+
+// canonicalTag writes a tag in verbatim form, or in shorthand for the tags
+// of the YAML schemas.
+func canonicalTag(tag string) string {
+	if suffix, ok := strings.CutPrefix(tag, "tag:yaml.org,2002:"); ok && isTagSuffix(suffix) {
+		return "!!" + suffix
+	}
+	return "!<" + tag + ">"
+}
+
+// pairs returns the entries of a map, sorted by key when sortKeys is set.
+func (d *docEmitter) pairs(n *Node) []NodePair {
+	if !d.sortKeys {
+		return n.Pairs
+	}
+
+	keys := make([]string, len(n.Pairs))
+	for i, pair := range n.Pairs {
+		keys[i] = sortKey(pair.Key, make(map[*Node]bool))
+	}
+
+	indices := make([]int, len(n.Pairs))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return keys[indices[i]] < keys[indices[j]]
+	})
+
+	pairs := make([]NodePair, len(n.Pairs))
+	for i, index := range indices {
+		pairs[i] = n.Pairs[index]
+	}
+	return pairs
+}
+
+// sortKey renders a key to compare it by: the canonical value of a scalar
+// followed by its tag, or the canonical form of a collection, without
+// anchors.
+func sortKey(n *Node, ancestors map[*Node]bool) string {
+	if n.Type != NODE_SEQUENCE && n.Type != NODE_MAP {
+		tag := n.ResolvedTag()
+		return canonicalValue(tag, n.Value) + "\x00" + tag
+	} else if ancestors[n] {
+		return "*"
+	}
+
+	ancestors[n] = true
+	defer delete(ancestors, n)
+
+	var b strings.Builder
+	b.WriteString(canonicalTag(n.ResolvedTag()) + "[")
+	for _, item := range n.Children {
+		b.WriteString(strconv.Quote(sortKey(item, ancestors)) + ",")
+	}
+	for _, pair := range n.Pairs {
+		b.WriteString(strconv.Quote(sortKey(pair.Key, ancestors)) + ":" + strconv.Quote(sortKey(pair.Value, ancestors)) + ",")
+	}
+	b.WriteString("]")
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package yaml
+
+import (
+	"bytes"
+	"testing"
+)
+
+func canonicalText(t *testing.T, text string) string {
+	t.Helper()
+	var out bytes.Buffer
+	emitter := NewEmitter(&out)
+	emitter.Canonical = true
+	if err := emitter.Emit(mustLoad(t, text)); err != nil {
+		t.Fatalf("%q: %v", text, err)
+	}
+	return out.String()
+}
+
+func TestCanonical(t *testing.T) {
+	expected := `%YAML 1.2
+---
+!!map {
+  ? !!str "name"
+  : !!str "web",
+  ? !!str "ports"
+  : !!seq [
+    &a1 !!int "80",
+    *a1,
+  ],
+}
+...
+`
+	if out := canonicalText(t, "name: web\nports: [&p 80, *p]\n"); out != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, out)
+	}
+}
+
+func TestCanonicalSameMeaning(t *testing.T) {
+	groups := [][]string{
+		{"16", "0x10", "0o20", "+16", "!!int 16"},
+		{"~", "null", "Null", "NULL", ""},
+		{"true", "True", "TRUE"},
+		{"1.5", "1.50", "15e-1", "+1.5"},
+		{".inf", ".Inf", "+.INF"},
+		{"'text'", "\"text\"", "text", "!!str text"},
+		{"{a: 1, b: [x, y]}", "b: [x, y]\na: 1\n", "{b: [x, y], a: 0x1}"},
+		{"{0x10: a, 2: b}", "{2: b, 16: a}"},
+		{"%YAML 1.1\n---\n{a: yes, b: 010}\n", "{a: true, b: 8}"},
+	}
+
+	for _, group := range groups {
+		first := canonicalText(t, group[0])
+		for _, text := range group[1:] {
+			if out := canonicalText(t, text); out != first {
+				t.Errorf("%q and %q differ:\n%v\n%v", group[0], text, first, out)
+			}
+		}
+	}
+}
+
+func TestCanonicalDistinct(t *testing.T) {
+	pairs := [][2]string{
+		{"16", "'16'"},
+		{"true", "'true'"},
+		{"~", "'~'"},
+		{"1", "1.0"},
+	}
+	for _, pair := range pairs {
+		if canonicalText(t, pair[0]) == canonicalText(t, pair[1]) {
+			t.Errorf("%q and %q should differ", pair[0], pair[1])
+		}
+	}
+}
@@ -0,0 +1,452 @@
+// Command yamlgo checks, formats, queries and edits YAML files, and dumps
+// the tokens and events the parser sees in them.
+//
+//	yamlgo lint [-strict] [file...]
+//	yamlgo fmt [-check] [-w] [-indent n] [-sort-keys] [-canonical] [file...]
+//	yamlgo get <query> [file]
+//	yamlgo set [-w] <query> <value> [file]
+//	yamlgo tokens [-json] [file]
+//	yamlgo events [file]
+//
+// Files default to the standard input, which can also be named "-". Query
+// expressions are those of yaml.Query, e.g. spec.containers[*].image.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	yaml "github.com/chryan/yamlgo"
+)
+
+// exit codes
+const (
+	exitOK       = 0
+	exitFindings = 1 // lint errors, unformatted files, no matches
+	exitUsage    = 2
+)
+
+type command struct {
+	run   func(args []string) int
+	usage string
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"lint":   {lint, "lint [-strict] [file...]\n\tcheck syntax, and with -strict also duplicate keys"},
+		"fmt":    {format, "fmt [-check] [-w] [-indent n] [-sort-keys] [-canonical] [file...]\n\treformat files"},
+		"get":    {get, "get <query> [file]\n\tprint the nodes matching a query"},
+		"set":    {set, "set [-w] <query> <value> [file]\n\tset the scalars matching a query, keeping the rest of the file as is"},
+		"tokens": {tokens, "tokens [-json] [file]\n\tdump the scanner's tokens"},
+		"events": {events, "events [file]\n\tdump the parser's events in the yaml-test-suite notation"},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "yamlgo: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(exitUsage)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: yamlgo <command> [arguments]\n\ncommands:")
+	for _, name := range []string{"lint", "fmt", "get", "set", "tokens", "events"} {
+		fmt.Fprintf(os.Stderr, "  %v\n", commands[name].usage)
+	}
+}
+
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: yamlgo %v\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// input is a file named on the command line, or the standard input.
+type input struct {
+	name string
+	data []byte
+}
+
+func readInputs(names []string) ([]input, error) {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	inputs := make([]input, 0, len(names))
+	for _, name := range names {
+		var data []byte
+		var err error
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+			name = "<stdin>"
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{name, data})
+	}
+	return inputs, nil
+}
+
+func readInput(names []string) (input, error) {
+	if len(names) > 1 {
+		return input{}, fmt.Errorf("expected one file, got %d", len(names))
+	}
+	inputs, err := readInputs(names)
+	if err != nil {
+		return input{}, err
+	}
+	return inputs[0], nil
+}
+
+// writeBack replaces a file's contents, keeping its permissions.
+func writeBack(in input, data []byte) error {
+	if in.name == "<stdin>" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	info, err := os.Stat(in.name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(in.name, data, info.Mode().Perm())
+}
+
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "yamlgo: %v\n", err)
+	return exitFindings
+}
+
+// location formats a mark as file:line:column, 1-based.
+func location(name string, mark yaml.Mark) string {
+	if mark == yaml.NullMark {
+		return name
+	}
+	return fmt.Sprintf("%v:%d:%d", name, mark.Line+1, mark.Column+1)
+}
+
+// report formats an error as file:line:column: message where it has a mark.
+func report(name string, err error) string {
+	switch err := err.(type) {
+	case *yaml.ParseError:
+		return fmt.Sprintf("%v: %v", location(name, err.Mark()), err.Message())
+	case *yaml.AliasError:
+		return fmt.Sprintf("%v: %v", location(name, err.Mark), err.Message())
+	}
+	return fmt.Sprintf("%v: %v", name, err)
+}
+
+func loadAll(in input) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(in.data))
+	decoder.Source = in.name
+	decoder.Warn = func(warning *yaml.ParseError) {
+		fmt.Fprintf(os.Stderr, "yamlgo: warning: %v\n", report(in.name, warning))
+	}
+
+	var docs []*yaml.Node
+	for {
+		doc, err := decoder.Decode()
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func lint(args []string) int {
+	flags := newFlags("lint")
+	strict := flags.Bool("strict", false, "also report duplicate map keys")
+	if flags.Parse(args) != nil {
+		return exitUsage
+	}
+
+	inputs, err := readInputs(flags.Args())
+	if err != nil {
+		return fail(err)
+	}
+
+	status := exitOK
+	for _, in := range inputs {
+		docs, err := loadAll(in)
+		if err != nil {
+			fmt.Println(report(in.name, err))
+			status = exitFindings
+			continue
+		}
+
+		if *strict {
+			for _, doc := range docs {
+				for _, problem := range duplicateKeys(doc) {
+					fmt.Println(problem)
+					status = exitFindings
+				}
+			}
+		}
+	}
+	return status
+}
+
+// duplicateKeys reports the scalar keys that occur more than once in a map.
+// Each map is checked once, however many aliases lead to it.
+func duplicateKeys(doc *yaml.Node) []string {
+	var problems []string
+	for path, node := range doc.WalkOnce() {
+		if !node.IsMap() {
+			continue
+		}
+
+		seen := make(map[string]*yaml.Node)
+		for _, pair := range node.Pairs {
+			if !pair.Key.IsScalar() && !pair.Key.IsNull() {
+				continue
+			}
+
+			key := canonicalKey(pair.Key)
+			if first, ok := seen[key]; ok {
+				name := pair.Key.Value
+				if pair.Key.IsNull() {
+					name = "null"
+				}
+				problems = append(problems, fmt.Sprintf("%v: duplicate key %q in %v (first at line %d)",
+					pair.Key.Origin(), name, pathString(path), first.Mark.Line+1))
+			} else {
+				seen[key] = pair.Key
+			}
+		}
+	}
+	return problems
+}
+
+// canonicalKey renders a key in the canonical form, in which keys meaning
+// the same, such as 0x10 and 16 or ~ and null, are spelled the same.
+func canonicalKey(key *yaml.Node) string {
+	var out bytes.Buffer
+	emitter := yaml.NewEmitter(&out)
+	emitter.Canonical = true
+	emitter.Emit(key)
+	return out.String()
+}
+
+func pathString(path yaml.Path) string {
+	if len(path) == 0 {
+		return "$"
+	}
+	return path.String()
+}
+
+func format(args []string) int {
+	flags := newFlags("fmt")
+	check := flags.Bool("check", false, "only list the files that aren't formatted, and fail if there are any")
+	write := flags.Bool("w", false, "write the result back to the files instead of standard output")
+	indent := flags.Int("indent", 2, "spaces to indent nested block collections by")
+	sortKeys := flags.Bool("sort-keys", false, "sort map entries by key")
+	canonical := flags.Bool("canonical", false, "write the canonical form")
+	if flags.Parse(args) != nil {
+		return exitUsage
+	}
+
+	inputs, err := readInputs(flags.Args())
+	if err != nil {
+		return fail(err)
+	}
+
+	status := exitOK
+	for _, in := range inputs {
+		docs, err := loadAll(in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, report(in.name, err))
+			status = exitFindings
+			continue
+		}
+
+		var out bytes.Buffer
+		emitter := yaml.NewEmitter(&out)
+		emitter.Indent = *indent
+		emitter.SortKeys = *sortKeys
+		emitter.Canonical = *canonical
+		for _, doc := range docs {
+			if err := emitter.Emit(doc); err != nil {
+				return fail(err)
+			}
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(out.Bytes(), in.data) {
+				fmt.Println(in.name)
+				status = exitFindings
+			}
+		case *write:
+			if !bytes.Equal(out.Bytes(), in.data) {
+				if err := writeBack(in, out.Bytes()); err != nil {
+					return fail(err)
+				}
+			}
+		default:
+			os.Stdout.Write(out.Bytes())
+		}
+	}
+	return status
+}
+
+func get(args []string) int {
+	flags := newFlags("get")
+	if flags.Parse(args) != nil || flags.NArg() < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	query, err := yaml.CompileQuery(flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	in, err := readInput(flags.Args()[1:])
+	if err != nil {
+		return fail(err)
+	}
+	docs, err := loadAll(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, report(in.name, err))
+		return exitFindings
+	}
+
+	found := false
+	for _, doc := range docs {
+		for _, match := range query.Find(doc) {
+			found = true
+			if match.Node.IsScalar() {
+				fmt.Println(match.Node.Value)
+			} else if match.Node.IsNull() {
+				fmt.Println("null")
+			} else if err := yaml.Emit(os.Stdout, match.Node); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if !found {
+		return exitFindings
+	}
+	return exitOK
+}
+
+func set(args []string) int {
+	flags := newFlags("set")
+	write := flags.Bool("w", false, "write the result back to the file instead of standard output")
+	if flags.Parse(args) != nil || flags.NArg() < 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	query, err := yaml.CompileQuery(flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	value := flags.Arg(1)
+	in, err := readInput(flags.Args()[2:])
+	if err != nil {
+		return fail(err)
+	}
+
+	editor, err := yaml.NewEditor(in.data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, report(in.name, err))
+		return exitFindings
+	}
+
+	found := false
+	for _, doc := range editor.Documents() {
+		for _, match := range query.Find(doc) {
+			found = true
+			if err := editor.SetScalar(match.Node, value); err != nil {
+				fmt.Fprintln(os.Stderr, report(in.name, err))
+				return exitFindings
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "yamlgo: %v: no node matches %v\n", in.name, query)
+		return exitFindings
+	}
+
+	if *write {
+		if err := writeBack(in, editor.Bytes()); err != nil {
+			return fail(err)
+		}
+	} else {
+		os.Stdout.Write(editor.Bytes())
+	}
+	return exitOK
+}
+
+func tokens(args []string) int {
+	flags := newFlags("tokens")
+	asJSON := flags.Bool("json", false, "write the tokens as a JSON array")
+	if flags.Parse(args) != nil {
+		return exitUsage
+	}
+
+	in, err := readInput(flags.Args())
+	if err != nil {
+		return fail(err)
+	}
+
+	dump := yaml.DumpTokens
+	if *asJSON {
+		dump = yaml.DumpTokensJSON
+	}
+	if err := dump(os.Stdout, bytes.NewReader(in.data)); err != nil {
+		fmt.Fprintln(os.Stderr, report(in.name, err))
+		return exitFindings
+	}
+	return exitOK
+}
+
+func events(args []string) int {
+	flags := newFlags("events")
+	if flags.Parse(args) != nil {
+		return exitUsage
+	}
+
+	in, err := readInput(flags.Args())
+	if err != nil {
+		return fail(err)
+	}
+
+	writer := yaml.NewEventWriter(os.Stdout)
+	parser := yaml.NewParser(bytes.NewReader(in.data))
+	for {
+		ok, err := parser.HandleNextDocument(writer)
+		if err != nil {
+			writer.Close()
+			fmt.Fprintln(os.Stderr, report(in.name, err))
+			return exitFindings
+		} else if !ok {
+			break
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
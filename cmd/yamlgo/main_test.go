@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run runs a command with the given standard input, returning what it wrote
+// and its exit code.
+func run(t *testing.T, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	dir := t.TempDir()
+	open := func(name, text string) *os.File {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	read := func(f *os.File) string {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		return string(data)
+	}
+
+	in, out, errOut := open("stdin", stdin), open("stdout", ""), open("stderr", "")
+	saved := [3]*os.File{os.Stdin, os.Stdout, os.Stderr}
+	os.Stdin, os.Stdout, os.Stderr = in, out, errOut
+	defer func() {
+		os.Stdin, os.Stdout, os.Stderr = saved[0], saved[1], saved[2]
+		in.Close()
+	}()
+
+	code = commands[args[0]].run(args[1:])
+	return read(out), read(errOut), code
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		stdin  string
+		args   []string
+		stdout string
+		code   int
+	}{
+		{"a: 1\n", []string{"lint"}, "", exitOK},
+		{"a: [1\n", []string{"lint"}, "<stdin>:2:1: ", exitFindings},
+		{"a: 1\na: 2\n", []string{"lint"}, "", exitOK},
+		{"a: 1\na: 2\n", []string{"lint", "-strict"}, "<stdin>:2:1: duplicate key \"a\" in $ (first at line 1)\n", exitFindings},
+		{"a: &x {k: 1, k: 2}\nb: *x\nc: *x\n", []string{"lint", "-strict"}, "<stdin>:1:14: duplicate key \"k\" in a (first at line 1)\n", exitFindings},
+		{"~: 1\nnull: 2\n0x10: 3\n16: 4\n'16': 5\n", []string{"lint", "-strict"},
+			"<stdin>:2:1: duplicate key \"null\" in $ (first at line 1)\n<stdin>:4:1: duplicate key \"16\" in $ (first at line 3)\n", exitFindings},
+		{"a:   {b: 1}\n", []string{"fmt"}, "a:\n  b: 1\n", exitOK},
+		{"b: 1\na: 2\n", []string{"fmt", "-sort-keys"}, "a: 2\nb: 1\n", exitOK},
+		{"a:   1\n", []string{"fmt", "-check"}, "<stdin>\n", exitFindings},
+		{"a: 1\n", []string{"fmt", "-check"}, "", exitOK},
+		{"a: {b: [x, y]}\n", []string{"get", "a.b[1]"}, "y\n", exitOK},
+		{"a: ~\n", []string{"get", "a"}, "null\n", exitOK},
+		{"a: 1\n", []string{"get", "b"}, "", exitFindings},
+		{"# keep\na: 1 # this\n", []string{"set", "a", "2"}, "# keep\na: 2 # this\n", exitOK},
+		{"a: 1\n", []string{"set", "b", "2"}, "", exitFindings},
+		{"a\n", []string{"events"}, "+STR\n+DOC\n=VAL :a\n-DOC\n-STR\n", exitOK},
+		{"", []string{"get"}, "", exitUsage},
+		{"", []string{"lint", "-frob"}, "", exitUsage},
+	}
+
+	for _, test := range tests {
+		stdout, _, code := run(t, test.stdin, test.args...)
+		if code != test.code {
+			t.Errorf("%v on %q: expected exit code %d, got %d", test.args, test.stdin, test.code, code)
+		}
+		if !strings.HasPrefix(stdout, test.stdout) || (test.stdout == "" || strings.HasSuffix(test.stdout, "\n")) && stdout != test.stdout {
+			t.Errorf("%v on %q: expected output %q, got %q", test.args, test.stdin, test.stdout, stdout)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	for _, args := range [][]string{{"tokens"}, {"tokens", "-json"}} {
+		stdout, _, code := run(t, "a: 1\n", args...)
+		if code != exitOK || !strings.Contains(stdout, "a") || !strings.Contains(stdout, "1") {
+			t.Errorf("%v: got exit code %d and %q", args, code, stdout)
+		}
+	}
+}
+
+// lint -strict checks each map once, so aliases that expand exponentially
+// don't hold it up.
+func TestLintAliases(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("a0: &a0 {k: 1}\n")
+	for i := 1; i < 60; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+	}
+	if stdout, stderr, code := run(t, b.String(), "lint", "-strict"); code != exitOK {
+		t.Errorf("expected no findings, got exit code %d: %q %q", code, stdout, stderr)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	_, stderr, code := run(t, "%YAML 1.3\n---\na\n", "lint")
+	if code != exitOK || !strings.Contains(stderr, "yamlgo: warning: <stdin>:1:") {
+		t.Errorf("expected a warning for YAML 1.3, got exit code %d and %q", code, stderr)
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.yaml")
+	if err := os.WriteFile(path, []byte("a:   1\nb: x # keep\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stderr, code := run(t, "", "set", "-w", "b", "y", path); code != exitOK {
+		t.Fatalf("set -w: exit code %d: %v", code, stderr)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a:   1\nb: y # keep\n" {
+		t.Errorf("set -w: expected only the value to change, got %q", data)
+	}
+	if _, stderr, code := run(t, "", "fmt", "-w", path); code != exitOK {
+		t.Fatalf("fmt -w: exit code %d: %v", code, stderr)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a: 1\nb: y\n"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file's permissions to be kept, got %v", info.Mode())
+	}
+}
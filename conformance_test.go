@@ -0,0 +1,292 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The conformance runner reads the official yaml-test-suite in the layout of
+// its "data" branch: one directory per case (or per sub-case, e.g. 4ABK/00)
+// holding in.yaml, test.event and, where they apply, in.json, out.yaml and an
+// empty "error" file flagging inputs that must be rejected.
+//
+// Point YAMLGO_TEST_SUITE at a checkout of that branch to run it. Without
+// it, the runner reads testdata/yaml-test-suite, which holds spec examples
+// and edge cases of our own in the same layout.
+
+const conformanceSuiteEnv = "YAMLGO_TEST_SUITE"
+
+// conformanceSkips lists the cases that are known not to pass yet, with the
+// reason.
+var conformanceSkips = map[string]string{
+	"error-directive-in-document": "a directive line inside a document is scanned as a directive, and the document then ends without an error",
+	"flow-empty-explicit-key":     `"? :" in a flow mapping opens a nested mapping for the empty key`,
+}
+
+type suiteCase struct {
+	id     string
+	name   string
+	input  []byte
+	events []string
+	json   []byte
+	output []byte
+	error  bool
+}
+
+func TestYAMLTestSuite(t *testing.T) {
+	root := os.Getenv(conformanceSuiteEnv)
+	if root == "" {
+		root = filepath.Join("testdata", "yaml-test-suite")
+	}
+
+	if _, err := os.Stat(root); err != nil {
+		t.Skipf("yaml-test-suite not available (%v); set %v to a checkout of its data branch", err, conformanceSuiteEnv)
+	}
+
+	cases, err := loadSuiteCases(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passed, failed, skipped := 0, 0, 0
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			if reason, ok := conformanceSkips[c.id]; ok {
+				skipped++
+				t.Skip(reason)
+			}
+
+			if err := runSuiteCase(c); err != nil {
+				failed++
+				t.Errorf("%v (%v): %v", c.id, c.name, err)
+				return
+			}
+			passed++
+		})
+	}
+
+	t.Logf("yaml-test-suite: %d passed, %d failed, %d skipped of %d cases", passed, failed, skipped, len(cases))
+}
+
+func loadSuiteCases(root string) (cases []*suiteCase, err error) {
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "in.yaml" {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		id, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+
+		c, err := loadSuiteCase(filepath.ToSlash(id), dir)
+		if err != nil {
+			return err
+		}
+		cases = append(cases, c)
+		return nil
+	})
+
+	sort.Slice(cases, func(i, j int) bool { return cases[i].id < cases[j].id })
+	return
+}
+
+func loadSuiteCase(id, dir string) (c *suiteCase, err error) {
+	c = &suiteCase{id: id}
+
+	if c.input, err = os.ReadFile(filepath.Join(dir, "in.yaml")); err != nil {
+		return nil, err
+	}
+
+	events, err := os.ReadFile(filepath.Join(dir, "test.event"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(events), "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			c.events = append(c.events, line)
+		}
+	}
+
+	// the optional files
+	if name, err := os.ReadFile(filepath.Join(dir, "===")); err == nil {
+		c.name = strings.TrimSpace(string(name))
+	}
+	c.json, _ = os.ReadFile(filepath.Join(dir, "in.json"))
+	c.output, _ = os.ReadFile(filepath.Join(dir, "out.yaml"))
+	_, err = os.Stat(filepath.Join(dir, "error"))
+	c.error = err == nil
+	return c, nil
+}
+
+func runSuiteCase(c *suiteCase) error {
+	events, err := recordSuiteEvents(c.input)
+	if c.error {
+		if err == nil {
+			return fmt.Errorf("expected an error, got none")
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	expected := normalizeSuiteEvents(c.events)
+	if err := compareSuiteEvents(expected, events); err != nil {
+		return err
+	}
+
+	// the suite's own re-serialisation has to load back to the same events
+	if c.output != nil {
+		events, err := recordSuiteEvents(c.output)
+		if err != nil {
+			return fmt.Errorf("out.yaml: %v", err)
+		}
+		if err := compareSuiteEvents(expected, events); err != nil {
+			return fmt.Errorf("out.yaml: %v", err)
+		}
+	}
+
+	if c.json != nil {
+		if err := compareSuiteJSON(c.input, c.json); err != nil {
+			return fmt.Errorf("in.json: %v", err)
+		}
+	}
+	return nil
+}
+
+func compareSuiteEvents(expected, events []string) error {
+	for i := 0; i < len(expected) || i < len(events); i++ {
+		want, got := "<none>", "<none>"
+		if i < len(expected) {
+			want = expected[i]
+		}
+		if i < len(events) {
+			got = events[i]
+		}
+		if want != got {
+			return fmt.Errorf("event %d: expected %q, got %q", i, want, got)
+		}
+	}
+	return nil
+}
+
+// compareSuiteJSON checks that ToJSON converts input to the JSON values
+// expected, one for each document; numbers are compared by value.
+func compareSuiteJSON(input, expected []byte) error {
+	var converted bytes.Buffer
+	if err := ToJSON(bytes.NewReader(input), &converted); err != nil {
+		return err
+	}
+
+	want, err := decodeSuiteJSON(expected)
+	if err != nil {
+		return fmt.Errorf("the expected JSON: %v", err)
+	}
+	got, err := decodeSuiteJSON(converted.Bytes())
+	if err != nil {
+		return fmt.Errorf("the converted JSON: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("expected %v, got %v", want, got)
+	}
+	return nil
+}
+
+func decodeSuiteJSON(text []byte) (values []any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	for {
+		var value any
+		if err = decoder.Decode(&value); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+func recordSuiteEvents(input []byte) ([]string, error) {
+	var events bytes.Buffer
+	writer := NewEventWriter(&events)
+	parser := NewParser(bytes.NewReader(input))
+	for {
+		ok, err := parser.HandleNextDocument(writer)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(events.String(), "\n"), "\n"), nil
+}
+
+// normalizeSuiteEvents reduces the suite's event notation to what the
+// EventHandler interface can express: there are no explicit document markers
+// or flow/block distinction, and scalars only tell plain (':') and non-plain
+// ('"') apart.
+func normalizeSuiteEvents(events []string) (out []string) {
+	for _, event := range events {
+		fields := strings.SplitN(event, " ", 2)
+		kind, rest := fields[0], ""
+		if len(fields) > 1 {
+			rest = fields[1]
+		}
+
+		switch kind {
+		case "+DOC", "-DOC":
+			rest = ""
+		case "+MAP", "+SEQ":
+			rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(rest, "{}"), "[]"))
+		case "=VAL":
+			// properties come first, the value is the rest after its style
+			// indicator
+			var props []string
+			for len(rest) > 0 && (rest[0] == '&' || rest[0] == '<') {
+				end := strings.Index(rest, " ")
+				if rest[0] == '<' {
+					end = strings.Index(rest, "> ") + 1
+				}
+				props = append(props, rest[:end])
+				rest = rest[end+1:]
+			}
+
+			if len(rest) == 0 {
+				rest = ":"
+			}
+			style, value := rest[:1], rest[1:]
+			tagged := len(props) > 0 && props[len(props)-1][0] == '<'
+			if tagged {
+				style = ":"
+			} else if style != ":" {
+				style = "\""
+			}
+
+			rest = strings.TrimSpace(strings.Join(props, " ") + " " + style + value)
+		}
+
+		if len(rest) > 0 {
+			event = kind + " " + rest
+		} else {
+			event = kind
+		}
+		out = append(out, event)
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package yaml
+
+import (
+	"context"
+	"io"
+)
+
+// Decoder reads the documents of a YAML stream into Node trees.
+type Decoder struct {
+	parser *Parser
+
+	// AllowRecursion lets an alias refer to a node it is nested in, which
+	// makes the tree cyclic. Such aliases are rejected by default, since
+	// most code walking a tree doesn't expect cycles.
+	AllowRecursion bool
+
+	// Source is recorded in every node decoded, to tell where it came from;
+	// typically the file name.
+	Source string
+
+	// DefaultVersion and Warn are as for Parser. Whatever the version, the
+	// nodes decoded are in YAML 1.2 terms: plain scalars that YAML 1.1 reads
+	// differently are rewritten, as yes to true (see ResolveTagVersion).
+	DefaultVersion Version
+	Warn           func(warning *ParseError)
+}
+
+func NewDecoder(reader io.Reader) *Decoder {
+	return &Decoder{parser: NewParser(reader)}
+}
+
+// NewDecoderSize returns a decoder with the given scanner lookahead; see
+// NewScannerSize.
+func NewDecoderSize(reader io.Reader, maxLookahead int) *Decoder {
+	return &Decoder{parser: NewParserSize(reader, maxLookahead)}
+}
+
+// Decode reads the next document. It returns io.EOF once the stream has no
+// documents left.
+func (d *Decoder) Decode() (*Node, error) {
+	return d.DecodeContext(context.Background())
+}
+
+// DecodeContext is Decode, stopping with a *CanceledError once ctx is done.
+func (d *Decoder) DecodeContext(ctx context.Context) (*Node, error) {
+	builder := newNodeBuilder()
+	builder.allowRecursion = d.AllowRecursion
+	builder.source = d.Source
+	d.parser.DefaultVersion, d.parser.Warn = d.DefaultVersion, d.Warn
+
+	ok, err := d.parser.HandleNextDocumentContext(ctx, builder)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, io.EOF
+	}
+	return builder.Root(), nil
+}
@@ -0,0 +1,365 @@
+package yaml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ChangeType int
+
+const (
+	CHANGE_ADDED ChangeType = iota
+	CHANGE_REMOVED
+	CHANGE_MODIFIED
+	CHANGE_MOVED
+)
+
+var changeTypeNames []string = []string{
+	"ADDED",
+	"REMOVED",
+	"MODIFIED",
+	"MOVED",
+}
+
+func (t ChangeType) String() string {
+	if t < 0 || int(t) >= len(changeTypeNames) {
+		return fmt.Sprintf("ChangeType(%d)", int(t))
+	}
+	return changeTypeNames[t]
+}
+
+// Change is a difference between two documents. Path is where the change is
+// in the old document and NewPath where it is in the new one; Path is nil
+// for an added node and NewPath for a removed one. From and To are the old
+// and new nodes, whose Marks locate the change on either side.
+type Change struct {
+	Type    ChangeType
+	Path    Path
+	NewPath Path
+	From    *Node
+	To      *Node
+}
+
+// Diff compares two documents and returns their differences in document
+// order. Nodes are compared by their resolved values (see ResolveTag), so
+// 1 and "1" differ while 1 and 0x1 do not. Map entries are matched by key;
+// sequence items are aligned on their longest common subsequence, with an
+// item found elsewhere in the other sequence reported as moved (after the
+// sequence's other changes) and the rest compared by position.
+func Diff(a, b *Node) []Change {
+	d := &differ{keys: make(map[*Node]string)}
+	d.diff(Path{}, Path{}, a, b)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+
+	// the comparison key of each node seen so far
+	keys map[*Node]string
+}
+
+func (d *differ) add(change Change) {
+	d.changes = append(d.changes, change)
+}
+
+func (d *differ) diff(pathA, pathB Path, a, b *Node) {
+	if d.key(a) == d.key(b) {
+		return
+	}
+
+	switch {
+	case a.Type == NODE_MAP && b.Type == NODE_MAP && a.ResolvedTag() == b.ResolvedTag():
+		d.diffMaps(pathA, pathB, a, b)
+	case a.Type == NODE_SEQUENCE && b.Type == NODE_SEQUENCE && a.ResolvedTag() == b.ResolvedTag():
+		d.diffSequences(pathA, pathB, a, b)
+	default:
+		d.add(Change{Type: CHANGE_MODIFIED, Path: pathA, NewPath: pathB, From: a, To: b})
+	}
+}
+
+func (d *differ) diffMaps(pathA, pathB Path, a, b *Node) {
+	index := make(map[string]int)
+	for i, pair := range b.Pairs {
+		if _, ok := index[d.key(pair.Key)]; !ok {
+			index[d.key(pair.Key)] = i
+		}
+	}
+
+	matched := make([]bool, len(b.Pairs))
+	for i, pair := range a.Pairs {
+		elemA := pairPathElement(i, pair)
+		j, ok := index[d.key(pair.Key)]
+		if !ok || matched[j] {
+			d.add(Change{Type: CHANGE_REMOVED, Path: pathA.child(elemA), From: pair.Value})
+			continue
+		}
+
+		matched[j] = true
+		d.diff(pathA.child(elemA), pathB.child(pairPathElement(j, b.Pairs[j])), pair.Value, b.Pairs[j].Value)
+	}
+
+	for j, pair := range b.Pairs {
+		if !matched[j] {
+			d.add(Change{Type: CHANGE_ADDED, NewPath: pathB.child(pairPathElement(j, pair)), To: pair.Value})
+		}
+	}
+}
+
+func (d *differ) diffSequences(pathA, pathB Path, a, b *Node) {
+	keysA := make([]string, len(a.Children))
+	for i, item := range a.Children {
+		keysA[i] = d.key(item)
+	}
+	keysB := make([]string, len(b.Children))
+	for j, item := range b.Children {
+		keysB[j] = d.key(item)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// keysA[i:] and keysB[j:]
+	lcs := make([][]int, len(keysA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(keysB)+1)
+	}
+	for i := len(keysA) - 1; i >= 0; i-- {
+		for j := len(keysB) - 1; j >= 0; j-- {
+			if keysA[i] == keysB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// the items off the common subsequence, in gaps between its items
+	type gap struct{ a, b []int }
+	var gaps []gap
+	var current gap
+	for i, j := 0, 0; i < len(keysA) || j < len(keysB); {
+		switch {
+		case i < len(keysA) && j < len(keysB) && keysA[i] == keysB[j]:
+			if len(current.a) > 0 || len(current.b) > 0 {
+				gaps = append(gaps, current)
+				current = gap{}
+			}
+			i++
+			j++
+		case j >= len(keysB) || i < len(keysA) && lcs[i+1][j] >= lcs[i][j+1]:
+			current.a = append(current.a, i)
+			i++
+		default:
+			current.b = append(current.b, j)
+			j++
+		}
+	}
+	if len(current.a) > 0 || len(current.b) > 0 {
+		gaps = append(gaps, current)
+	}
+
+	// an item taken out in one place and put back in another has moved
+	movedA := make(map[int]bool)
+	movedB := make(map[int]bool)
+	var moves []Change
+	for _, g := range gaps {
+		for _, i := range g.a {
+			for _, h := range gaps {
+				for _, j := range h.b {
+					if !movedA[i] && !movedB[j] && keysA[i] == keysB[j] {
+						movedA[i], movedB[j] = true, true
+						moves = append(moves, Change{
+							Type:    CHANGE_MOVED,
+							Path:    pathA.child(PathElement{Index: i, IsIndex: true}),
+							NewPath: pathB.child(PathElement{Index: j, IsIndex: true}),
+							From:    a.Children[i],
+							To:      b.Children[j],
+						})
+					}
+				}
+			}
+		}
+	}
+
+	// the rest are compared by their position in the gap
+	for _, g := range gaps {
+		var restA, restB []int
+		for _, i := range g.a {
+			if !movedA[i] {
+				restA = append(restA, i)
+			}
+		}
+		for _, j := range g.b {
+			if !movedB[j] {
+				restB = append(restB, j)
+			}
+		}
+
+		for k := 0; k < len(restA) || k < len(restB); k++ {
+			switch {
+			case k >= len(restB):
+				i := restA[k]
+				d.add(Change{Type: CHANGE_REMOVED, Path: pathA.child(PathElement{Index: i, IsIndex: true}), From: a.Children[i]})
+			case k >= len(restA):
+				j := restB[k]
+				d.add(Change{Type: CHANGE_ADDED, NewPath: pathB.child(PathElement{Index: j, IsIndex: true}), To: b.Children[j]})
+			default:
+				i, j := restA[k], restB[k]
+				d.diff(pathA.child(PathElement{Index: i, IsIndex: true}), pathB.child(PathElement{Index: j, IsIndex: true}), a.Children[i], b.Children[j])
+			}
+		}
+	}
+	d.changes = append(d.changes, moves...)
+}
+
+// key returns compareKey(n), remembering the keys of n and of all the
+// collections below it, so that each node is keyed once.
+func (d *differ) key(n *Node) string {
+	return nodeKey(n, make(map[*Node]bool), d.keys)
+}
+
+// compareKey returns a string that two nodes share exactly when their
+// resolved tags and values are the same, map entries in any order. The key
+// of a collection is a hash of the keys of its items or entries, so keying
+// a node takes time linear in its size.
+func compareKey(n *Node, ancestors map[*Node]bool) string {
+	return nodeKey(n, ancestors, nil)
+}
+
+// nodeKey is compareKey, looking up and storing collection keys in keys
+// unless it is nil.
+func nodeKey(n *Node, ancestors map[*Node]bool, keys map[*Node]string) string {
+	tag := n.ResolvedTag()
+	if n.Type != NODE_SEQUENCE && n.Type != NODE_MAP {
+		return strconv.Quote(tag) + strconv.Quote(normalizeScalar(tag, n.Value))
+	} else if key, ok := keys[n]; ok {
+		return key
+	} else if ancestors[n] {
+		// a recursive node; good enough to tell most trees apart
+		return "*"
+	}
+
+	ancestors[n] = true
+	defer delete(ancestors, n)
+
+	hash := sha256.New()
+	io.WriteString(hash, strconv.Quote(tag)+"[")
+	for _, item := range n.Children {
+		io.WriteString(hash, nodeKey(item, ancestors, keys)+",")
+	}
+
+	// entries compare regardless of their order
+	entries := make([]string, len(n.Pairs))
+	for i, pair := range n.Pairs {
+		entries[i] = nodeKey(pair.Key, ancestors, keys) + ":" + nodeKey(pair.Value, ancestors, keys) + ","
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		io.WriteString(hash, entry)
+	}
+
+	key := "#" + hex.EncodeToString(hash.Sum(nil))
+	if keys != nil {
+		keys[n] = key
+	}
+	return key
+}
+
+// normalizeScalar spells the value of a core schema scalar one way, so that
+// equal values compare equal.
+func normalizeScalar(tag string, value string) string {
+	switch tag {
+	case TAG_NULL:
+		return ""
+	case TAG_BOOL:
+		return strings.ToLower(value)
+	case TAG_INT:
+		digits, base := strings.TrimPrefix(value, "+"), 10
+		if strings.HasPrefix(digits, "0o") {
+			digits, base = digits[2:], 8
+		} else if strings.HasPrefix(digits, "0x") {
+			digits, base = digits[2:], 16
+		}
+		if i, ok := new(big.Int).SetString(digits, base); ok {
+			return i.String()
+		}
+	case TAG_FLOAT:
+		switch strings.ToLower(value) {
+		case ".inf", "+.inf":
+			return strconv.FormatFloat(math.Inf(1), 'g', -1, 64)
+		case "-.inf":
+			return strconv.FormatFloat(math.Inf(-1), 'g', -1, 64)
+		case ".nan":
+			return "NaN"
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	return value
+}
+
+// String renders the change as a line of WriteDiff's output.
+func (c Change) String() string {
+	switch c.Type {
+	case CHANGE_ADDED:
+		return fmt.Sprintf("+ %v: %v (line %d)", diffPath(c.NewPath), diffValue(c.To), c.To.Mark.Line+1)
+	case CHANGE_REMOVED:
+		return fmt.Sprintf("- %v: %v (line %d)", diffPath(c.Path), diffValue(c.From), c.From.Mark.Line+1)
+	case CHANGE_MODIFIED:
+		return fmt.Sprintf("~ %v: %v -> %v (line %d -> %d)", diffPath(c.Path), diffValue(c.From), diffValue(c.To), c.From.Mark.Line+1, c.To.Mark.Line+1)
+	case CHANGE_MOVED:
+		return fmt.Sprintf("> %v -> %v: %v (line %d -> %d)", diffPath(c.Path), diffPath(c.NewPath), diffValue(c.From), c.From.Mark.Line+1, c.To.Mark.Line+1)
+	}
+	return c.Type.String()
+}
+
+// WriteDiff writes one line per change:
+//
+//	~ spec.replicas: 3 -> "3" (line 7 -> 7)
+//	+ spec.paused: true (line 9)
+//	- metadata.labels.tier: web (line 5)
+//	> spec.ports[2] -> spec.ports[0]: 443 (line 12 -> 10)
+//
+// Lines are 1-based; nodes without a position show line 0.
+func WriteDiff(writer io.Writer, changes []Change) error {
+	for _, change := range changes {
+		if _, err := io.WriteString(writer, change.String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffPath(path Path) string {
+	if len(path) == 0 {
+		return "$"
+	}
+	return path.String()
+}
+
+// diffValue renders a scalar as the emitter would, quoted when it would
+// otherwise read as another type, and a collection by its size.
+func diffValue(n *Node) string {
+	switch n.Type {
+	case NODE_SEQUENCE:
+		return fmt.Sprintf("[%d items]", len(n.Children))
+	case NODE_MAP:
+		return fmt.Sprintf("{%d entries}", len(n.Pairs))
+	}
+
+	text := emitScalar(n, 0)
+	if strings.Contains(text, "\n") {
+		text = doubleQuote(n.Value)
+	}
+	if tag := emitTag(n); len(tag) > 0 {
+		text = tag + " " + text
+	}
+	return text
+}
@@ -0,0 +1,81 @@
+package yaml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := mustLoad(t, `metadata:
+  name: web
+  labels:
+    tier: web
+spec:
+  replicas: 3
+  ports: [80, 8080, 443]
+  count: 0x10
+`)
+	b := mustLoad(t, `metadata:
+  labels: {}
+  name: web
+spec:
+  replicas: "3"
+  ports: [443, 80, 8080]
+  count: 16
+  paused: true
+`)
+
+	var out bytes.Buffer
+	if err := WriteDiff(&out, Diff(a, b)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `- metadata.labels.tier: web (line 4)
+~ spec.replicas: 3 -> "3" (line 6 -> 5)
+> spec.ports[2] -> spec.ports[0]: 443 (line 7 -> 6)
++ spec.paused: true (line 8)
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, out.String())
+	}
+}
+
+func TestDiffSame(t *testing.T) {
+	pairs := [][2]string{
+		{"{a: 1, b: [x, {c: d}]}", "b: [x, {c: d}]\na: 0x1\n"},
+		{"~", "null"},
+		{"[1.0, true]", "[1.00, True]"},
+		{"a: &x {k: v}\nb: *x\n", "a: {k: v}\nb: {k: v}\n"},
+	}
+	for _, pair := range pairs {
+		if changes := Diff(mustLoad(t, pair[0]), mustLoad(t, pair[1])); len(changes) > 0 {
+			t.Errorf("%q and %q: expected no changes, got %v", pair[0], pair[1], changes)
+		}
+	}
+}
+
+func TestDiffSequences(t *testing.T) {
+	a := mustLoad(t, "[a, b, c, d]")
+	b := mustLoad(t, "[a, x, c, d, e]")
+
+	var lines []string
+	for _, change := range Diff(a, b) {
+		lines = append(lines, change.Type.String()+" "+diffPath(change.Path)+" "+diffPath(change.NewPath))
+	}
+	expected := "MODIFIED [1] [1]|ADDED $ [4]"
+	if got := strings.Join(lines, "|"); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestDiffDeep(t *testing.T) {
+	// keys are computed once per node, so a deep document diffs quickly
+	depth := 2000
+	text := strings.Repeat("[", depth) + "a" + strings.Repeat("]", depth)
+	changed := strings.Repeat("[", depth) + "b" + strings.Repeat("]", depth)
+
+	changes := Diff(mustLoad(t, text), mustLoad(t, changed))
+	if len(changes) != 1 || changes[0].Type != CHANGE_MODIFIED || len(changes[0].Path) != depth {
+		t.Errorf("expected one change at depth %d, got %d", depth, len(changes))
+	}
+}
@@ -11,6 +11,13 @@ type Directives struct {
 	Tags    map[string]string
 }
 
+// before12 reports whether the version is one before YAML 1.2, which reads
+// NEL as a line break and resolves more plain scalars as booleans and
+// octal integers.
+func (v Version) before12() bool {
+	return v.Major == 1 && v.Minor < 2
+}
+
 func NewDirectives() *Directives {
 	return &Directives{Version: Version{true, 1, 2}}
 }
@@ -0,0 +1,467 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Editor makes targeted changes to a YAML source. Each change rewrites only
+// the bytes of the scalar it sets or the entry it inserts; indentation,
+// quoting, comments and ordering everywhere else are kept as they are.
+//
+//	editor, err := NewEditor(src)
+//	...
+//	for _, m := range query.Find(editor.Documents()[0]) {
+//		editor.SetScalar(m.Node, "nginx:1.27")
+//	}
+//	src = editor.Bytes()
+//
+// Nodes inserted by an Editor have no source position, so they can't be
+// edited further; make a new Editor from Bytes for that.
+type Editor struct {
+	src       []byte
+	docs      []*Node
+	tokens    []Token
+	lastMarks map[*Node]Mark
+	edits     []edit
+	newline   string // the source's line break
+
+	// where each document starts, and its YAML version
+	docStarts []int
+	versions  []Version
+}
+
+// edit replaces src[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+
+	// where the collection an insert goes into starts; of the inserts at
+	// an offset, the innermost collection's, which starts last, goes first
+	within int
+}
+
+// NewEditor parses every document in src for editing.
+func NewEditor(src []byte) (*Editor, error) {
+	e := &Editor{src: src, lastMarks: make(map[*Node]Mark), newline: "\n"}
+	if nl := bytes.IndexByte(src, '\n'); nl > 0 && src[nl-1] == '\r' {
+		e.newline = "\r\n"
+	}
+
+	parser := NewParser(bytes.NewReader(src))
+	for {
+		builder := newNodeBuilder()
+		builder.lastMarks = e.lastMarks
+		var start docStart
+
+		ok, err := parser.HandleNextDocument(Tee(&start, builder))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		e.docs = append(e.docs, builder.Root())
+		e.docStarts = append(e.docStarts, start.pos)
+		e.versions = append(e.versions, builder.version)
+	}
+
+	// the tokens tell where each node's text starts and ends
+	tokens := NewTokenStream(bytes.NewReader(src))
+	for tokens.Next() {
+		e.tokens = append(e.tokens, *tokens.Token())
+	}
+	if err := tokens.Err(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Documents returns the document trees, which can be read and queried to
+// find the nodes to edit.
+func (e *Editor) Documents() []*Node {
+	return e.docs
+}
+
+// Bytes returns the source with all the edits applied.
+func (e *Editor) Bytes() []byte {
+	edits := make([]edit, len(e.edits))
+	copy(edits, e.edits)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].within > edits[j].within
+	})
+
+	var out bytes.Buffer
+	pos := 0
+	for _, ed := range edits {
+		out.Write(e.src[pos:ed.start])
+		out.WriteString(ed.text)
+		pos = ed.end
+	}
+	out.Write(e.src[pos:])
+	return out.Bytes()
+}
+
+// SetScalar rewrites the text of a scalar (or null) node to spell value, in
+// the scalar's original style where that can hold the value and
+// double-quoted otherwise. A plain scalar is also quoted when the new value
+// would otherwise read as a number, boolean or null in place of a string.
+//
+// A node reached through an alias is its anchor's node, so setting it
+// rewrites the anchored scalar.
+func (e *Editor) SetScalar(node *Node, value string) error {
+	if node.Type != NODE_SCALAR && node.Type != NODE_NULL {
+		return &ParseError{node.Mark, ERR_EDIT_NOT_SCALAR}
+	}
+
+	i := e.content(node)
+	if i < 0 || (e.tokens[i].Type != TOKEN_PLAIN_SCALAR && e.tokens[i].Type != TOKEN_NON_PLAIN_SCALAR) {
+		return &ParseError{node.Mark, ERR_EDIT_NO_SOURCE}
+	}
+
+	start, end := e.tokens[i].Mark.Pos, e.tokenEnd(i)
+	version := e.version(node)
+	text, plain := e.renderScalar(node, start, value, version)
+	e.replace(start, end, text)
+
+	node.Type = NODE_SCALAR
+	node.Value = value
+	if node.Tag == "" || node.Tag == "?" || node.Tag == "!" {
+		node.Tag = "!"
+		if plain {
+			// the tree is in YAML 1.2 terms, like the decoder's
+			node.Tag, node.Value = coreScalar("?", value, version)
+		}
+	}
+	return nil
+}
+
+// SetKey sets the value of key in a map to the scalar value. An existing
+// entry is changed with SetScalar; otherwise the entry is added after the
+// map's last one, on a line of its own in a block map. The inserted key and
+// value are plain unless they need quoting, and values spelling a number,
+// boolean or null are quoted so that they stay strings.
+func (e *Editor) SetKey(mapping *Node, key, value string) error {
+	if mapping.Type != NODE_MAP {
+		return &ParseError{mapping.Mark, ERR_EDIT_NOT_MAP}
+	}
+	if node := mapping.Get(key); node != nil {
+		return e.SetScalar(node, value)
+	}
+
+	version := e.version(mapping)
+	keyNode, keyText := newEditorScalar(key, version)
+	valueNode, valueText := newEditorScalar(value, version)
+	if err := e.insert(mapping, TOKEN_FLOW_MAP_START, TOKEN_BLOCK_MAP_START, keyText+": "+valueText); err != nil {
+		return err
+	}
+
+	mapping.Pairs = append(mapping.Pairs, NodePair{keyNode, valueNode})
+	return nil
+}
+
+// Append adds the scalar value to the end of a sequence.
+func (e *Editor) Append(seq *Node, value string) error {
+	if seq.Type != NODE_SEQUENCE {
+		return &ParseError{seq.Mark, ERR_EDIT_NOT_SEQ}
+	}
+
+	node, text := newEditorScalar(value, e.version(seq))
+	if err := e.insert(seq, TOKEN_FLOW_SEQ_START, TOKEN_BLOCK_SEQ_START, text); err != nil {
+		return err
+	}
+
+	seq.Children = append(seq.Children, node)
+	return nil
+}
+
+// insert adds an entry or item to the end of a collection: in a flow
+// collection after a comma, and in a block collection on a new line after
+// the collection's last one, with the same indentation.
+func (e *Editor) insert(collection *Node, flowStart, blockStart TokenType, text string) error {
+	i := e.content(collection)
+	if i < 0 {
+		return &ParseError{collection.Mark, ERR_EDIT_NO_SOURCE}
+	}
+
+	token := &e.tokens[i]
+	within := token.Mark.Pos
+	switch token.Type {
+	case flowStart:
+		if collection.Len() == 0 {
+			e.insertAt(token.EndMark.Pos, within, text)
+		} else {
+			e.insertAt(e.end(collection), within, ", "+text)
+		}
+	case blockStart:
+		if blockStart == TOKEN_BLOCK_SEQ_START {
+			text = "- " + text
+		}
+		text = strings.Repeat(" ", token.Mark.Column) + text
+
+		end := e.end(collection)
+		if nl := bytes.IndexByte(e.src[end:], '\n'); nl >= 0 {
+			e.insertAt(end+nl+1, within, text+e.newline)
+		} else {
+			e.insertAt(len(e.src), within, e.newline+text)
+		}
+	default:
+		// e.g. a compact map in a flow sequence
+		return &ParseError{collection.Mark, ERR_EDIT_NO_SOURCE}
+	}
+	return nil
+}
+
+// replace records an edit, overriding an earlier one of the same span.
+func (e *Editor) replace(start, end int, text string) {
+	if start < end {
+		for i := range e.edits {
+			if e.edits[i].start == start && e.edits[i].end == end {
+				e.edits[i].text = text
+				return
+			}
+		}
+	}
+	e.edits = append(e.edits, edit{start: start, end: end, text: text})
+}
+
+// insertAt records the insertion of text at offset pos into the collection
+// starting at offset within.
+func (e *Editor) insertAt(pos, within int, text string) {
+	e.edits = append(e.edits, edit{start: pos, end: pos, text: text, within: within})
+}
+
+// content returns the index of the token a node's content starts with,
+// after its properties, or -1.
+func (e *Editor) content(node *Node) int {
+	if node.Mark == NullMark {
+		return -1
+	}
+	return e.contentAt(node.Mark.Pos)
+}
+
+func (e *Editor) contentAt(pos int) int {
+	i := sort.Search(len(e.tokens), func(i int) bool {
+		return e.tokens[i].Mark.Pos >= pos
+	})
+	for i < len(e.tokens) && (e.tokens[i].Type == TOKEN_TAG || e.tokens[i].Type == TOKEN_ANCHOR) {
+		i++
+	}
+	if i >= len(e.tokens) {
+		return -1
+	}
+	return i
+}
+
+// end returns the offset just past the text of a collection.
+func (e *Editor) end(collection *Node) int {
+	if mark, ok := e.lastMarks[collection]; ok {
+		if i := e.contentAt(mark.Pos); i >= 0 {
+			return e.tokenEnd(i)
+		}
+	}
+	return e.tokenEnd(e.content(collection))
+}
+
+// tokenEnd returns the offset just past a token's text, or past the whole
+// collection for a flow collection start. Trailing whitespace and line
+// breaks, which block and multi-line scalars take in, are left out.
+func (e *Editor) tokenEnd(i int) int {
+	start := e.tokens[i].Mark.Pos
+	end := e.tokens[i].EndMark.Pos
+
+	switch e.tokens[i].Type {
+	case TOKEN_FLOW_SEQ_START, TOKEN_FLOW_MAP_START:
+		depth := 0
+		for ; i < len(e.tokens); i++ {
+			switch e.tokens[i].Type {
+			case TOKEN_FLOW_SEQ_START, TOKEN_FLOW_MAP_START:
+				depth++
+			case TOKEN_FLOW_SEQ_END, TOKEN_FLOW_MAP_END:
+				depth--
+			}
+			if depth == 0 {
+				end = e.tokens[i].EndMark.Pos
+				break
+			}
+		}
+	}
+
+	end = min(end, len(e.src))
+	for end > start && strings.IndexByte(" \t\r\n", e.src[end-1]) >= 0 {
+		end--
+	}
+	return end
+}
+
+// docStart is an EventHandler recording where a document starts.
+type docStart struct {
+	BaseHandler
+	pos int
+}
+
+func (d *docStart) DocumentStart(mark Mark) {
+	d.pos = mark.Pos
+}
+
+// version returns the YAML version of the document a node is in.
+func (e *Editor) version(node *Node) Version {
+	i := sort.Search(len(e.docStarts), func(i int) bool {
+		return e.docStarts[i] > node.Mark.Pos
+	})
+	if i == 0 {
+		return Version{}
+	}
+	return e.versions[i-1]
+}
+
+// renderScalar spells value in the style of the scalar that starts at
+// offset start, reporting whether it came out plain.
+func (e *Editor) renderScalar(node *Node, start int, value string, version Version) (text string, plain bool) {
+	switch e.src[start] {
+	case '"':
+	case '\'':
+		if canSingleQuote(value) {
+			return singleQuote(value), false
+		}
+	case '|':
+		if text, ok := e.literalScalar(start, value); ok {
+			return text, false
+		}
+	case '>':
+	default:
+		wasString := node.Type == NODE_SCALAR && ResolveTag(node.Tag, node.Value) == TAG_STR
+		if isPlainSafe(value) && (!wasString || ResolveTagVersion("?", value, version) == TAG_STR) {
+			return value, true
+		}
+	}
+	return doubleQuote(value), false
+}
+
+// literalScalar spells value as a literal block scalar indented like the
+// one at offset start, if it can be without an explicit indentation
+// indicator or keeping trailing line breaks.
+func (e *Editor) literalScalar(start int, value string) (string, bool) {
+	chomp := ""
+	if !strings.HasSuffix(value, "\n") {
+		chomp = "-"
+	}
+	body := strings.TrimSuffix(value, "\n")
+	if len(body) == 0 || body[0] == ' ' || strings.HasSuffix(body, "\n") {
+		return "", false
+	}
+
+	// take the indentation of the first content line
+	indent := -1
+	lines := strings.Split(string(e.src[start:]), "\n")
+	for _, line := range lines[1:] {
+		if len(strings.TrimSpace(line)) > 0 {
+			indent = len(line) - len(strings.TrimLeft(line, " "))
+			break
+		}
+	}
+	if indent <= 0 {
+		return "", false
+	}
+
+	text := "|" + chomp
+	for _, line := range strings.Split(body, "\n") {
+		text += e.newline
+		if len(line) > 0 {
+			text += strings.Repeat(" ", indent) + line
+		}
+	}
+	return text, true
+}
+
+// newEditorScalar returns a node for an inserted scalar and its text, plain
+// where that reads as a string in a document of the given version.
+func newEditorScalar(value string, version Version) (*Node, string) {
+	if isPlainSafe(value) && ResolveTagVersion("?", value, version) == TAG_STR {
+		return &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "?", Value: value}, value
+	}
+	return &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "!", Value: value}, doubleQuote(value)
+}
+
+// isPlainSafe reports whether value can be written as a plain scalar in
+// both block and flow context and read back unchanged.
+func isPlainSafe(value string) bool {
+	if len(value) == 0 || strings.HasPrefix(value, "---") || strings.HasPrefix(value, "...") {
+		return false
+	}
+
+	switch value[0] {
+	case '-', '?', ':':
+		if len(value) == 1 || value[1] == ' ' {
+			return false
+		}
+	case ',', '[', ']', '{', '}', '#', '&', '*', '!', '|', '>', '\'', '"', '%', '@', '`', ' ', '\t':
+		return false
+	}
+
+	last := value[len(value)-1]
+	if last == ' ' || last == '\t' || last == ':' {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.ContainsAny(value, ",[]{}") {
+		return false
+	}
+	for _, ch := range value {
+		if ch < ' ' || ch == 0x7f || !unicode.IsPrint(ch) && ch != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func canSingleQuote(value string) bool {
+	for _, ch := range value {
+		if ch < ' ' || ch == 0x7f || !unicode.IsPrint(ch) && ch != ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func singleQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func doubleQuote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, ch := range value {
+		switch ch {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		default:
+			switch {
+			case ch >= ' ' && ch != 0x7f && (ch == ' ' || unicode.IsPrint(ch)):
+				b.WriteRune(ch)
+			case ch <= 0xff:
+				fmt.Fprintf(&b, `\x%02X`, ch)
+			case ch <= 0xffff:
+				fmt.Fprintf(&b, `\u%04X`, ch)
+			default:
+				fmt.Fprintf(&b, `\U%08X`, ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
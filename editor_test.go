@@ -0,0 +1,139 @@
+package yaml
+
+import (
+	"testing"
+)
+
+func mustEdit(t *testing.T, src string) (*Editor, *Node) {
+	t.Helper()
+	editor, err := NewEditor([]byte(src))
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return editor, editor.Documents()[0]
+}
+
+func checkEdited(t *testing.T, editor *Editor, expected string) {
+	t.Helper()
+	out := string(editor.Bytes())
+	if out != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, out)
+	}
+	if _, err := NewEditor([]byte(out)); err != nil {
+		t.Errorf("the edited source doesn't load: %v", err)
+	}
+}
+
+func TestEditorSetScalar(t *testing.T) {
+	tests := []struct {
+		src, key, value, expected string
+	}{
+		{"a: 1 # count\nb: x\n", "a", "2", "a: 2 # count\nb: x\n"},
+		{"a: 1\n", "a", "true", "a: true\n"},
+		{"a: name\n", "a", "true", "a: \"true\"\n"},
+		{"a: name\n", "a", "0x10", "a: \"0x10\"\n"},
+		{"a: 'single'\n", "a", "it's", "a: 'it''s'\n"},
+		{"a: \"double\"\n", "a", "x", "a: \"x\"\n"},
+		{"a: |\n  one\n  two\nb: c\n", "a", "three\nfour\n", "a: |\n  three\n  four\nb: c\n"},
+		{"a: ~\nb: c\n", "a", "set", "a: set\nb: c\n"},
+		{"a: &x old\nb: *x\n", "b", "new", "a: &x new\nb: *x\n"},
+		{"{a: 1, b: 2}\n", "b", "3", "{a: 1, b: 3}\n"},
+	}
+
+	for _, test := range tests {
+		editor, doc := mustEdit(t, test.src)
+		if err := editor.SetScalar(doc.Get(test.key), test.value); err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		checkEdited(t, editor, test.expected)
+	}
+}
+
+func TestEditorInsert(t *testing.T) {
+	editor, doc := mustEdit(t, "spec:\n  list:\n  - a\n  - b\n  flow: [x]\n  empty: {}\nother: 1\n")
+	spec := doc.Get("spec")
+	if err := editor.SetKey(spec, "new", "val"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Append(spec.Get("list"), "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Append(spec.Get("flow"), "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(spec.Get("empty"), "k", "null"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(doc, "last", "z"); err != nil {
+		t.Fatal(err)
+	}
+	checkEdited(t, editor, "spec:\n  list:\n  - a\n  - b\n  - c\n  flow: [x, y]\n  empty: {k: \"null\"}\n  new: val\nother: 1\nlast: z\n")
+}
+
+func TestEditorInsertAtEnd(t *testing.T) {
+	// the innermost collection's insert goes first at the end of the input
+	editor, doc := mustEdit(t, "a:\n  b:\n    - x")
+	if err := editor.SetKey(doc, "c", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(doc.Get("a"), "e", "f"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Append(doc.Get("a").Get("b"), "y"); err != nil {
+		t.Fatal(err)
+	}
+	checkEdited(t, editor, "a:\n  b:\n    - x\n    - y\n  e: f\nc: d")
+}
+
+func TestEditorCRLF(t *testing.T) {
+	editor, doc := mustEdit(t, "a: |\r\n  text\r\nlist:\r\n  - x\r\n")
+	if err := editor.SetScalar(doc.Get("a"), "one\ntwo\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Append(doc.Get("list"), "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(doc, "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	checkEdited(t, editor, "a: |\r\n  one\r\n  two\r\nlist:\r\n  - x\r\n  - y\r\nb: c\r\n")
+}
+
+func TestEditorErrors(t *testing.T) {
+	editor, doc := mustEdit(t, "a: [1]\nb: {c: d}\n")
+	if err := editor.SetScalar(doc.Get("a"), "x"); err == nil {
+		t.Error("setting a sequence as a scalar: expected an error")
+	}
+	if err := editor.SetKey(doc.Get("a"), "k", "v"); err == nil {
+		t.Error("setting a key of a sequence: expected an error")
+	}
+	if err := editor.Append(doc.Get("b"), "v"); err == nil {
+		t.Error("appending to a map: expected an error")
+	}
+}
+
+// Values are quoted where a plain scalar would mean something else under
+// the YAML version of the document they go into.
+func TestEditorVersion(t *testing.T) {
+	const src = "a: 1\n...\n%YAML 1.1\n---\nname: x\nlist: [x]\n"
+	editor, err := NewEditor([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, doc := editor.Documents()[0], editor.Documents()[1]
+
+	if err := editor.SetScalar(doc.Get("name"), "yes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(doc, "on", "010"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Append(doc.Get("list"), "off"); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.SetKey(first, "b", "yes"); err != nil {
+		t.Fatal(err)
+	}
+	checkEdited(t, editor, "a: 1\nb: yes\n...\n%YAML 1.1\n---\nname: \"yes\"\nlist: [x, \"off\"]\n\"on\": \"010\"\n")
+}
@@ -0,0 +1,366 @@
+package yaml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Emitter writes Node trees as YAML documents in block style:
+//
+//	name: web
+//	ports:
+//	  - 80
+//	  - 443
+//	labels: {}
+//	script: |
+//	  echo hi
+//
+// Scalars are written plain where that reads back the same, and quoted
+// otherwise; multi-line strings are written as literal block scalars.
+// Documents after the first are preceded by "---".
+//
+// Nodes with an Anchor name get that anchor, and later occurrences of the
+// same node are written as aliases to it. Other shared nodes are written out
+// in full each time.
+//
+// An Emitter is also an EventHandler, so a Parser can feed it directly:
+// each document is written once its DocumentEnd event arrives.
+type Emitter struct {
+	writer  io.Writer
+	err     error
+	docs    int
+	builder *nodeBuilder
+
+	// Indent is the number of spaces block collections nested in a map are
+	// indented by.
+	Indent int
+
+	// Canonical selects the canonical form of the YAML spec, which spells
+	// out every tag and quotes every scalar, for output that is the same
+	// for all inputs that mean the same: scalars are spelled one way for
+	// their tag and map entries are sorted, whatever SortKeys says.
+	Canonical bool
+
+	// SortKeys writes map entries in the order of their keys instead of
+	// their original order.
+	SortKeys bool
+}
+
+func NewEmitter(writer io.Writer) *Emitter {
+	return &Emitter{writer: writer, Indent: 2}
+}
+
+// Emit writes node as a document. Once writing has failed, Emit keeps
+// returning the same error.
+func (e *Emitter) Emit(node *Node) (err error) {
+	if e.err != nil {
+		return e.err
+	}
+
+	// Handle emitting panics.
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("yamlgo: %v", r)
+			}
+		}
+	}()
+
+	d := &docEmitter{
+		indent:    max(e.Indent, 1),
+		sortKeys:  e.SortKeys,
+		anchors:   make(map[*Node]bool),
+		ancestors: make(map[*Node]bool),
+		names:     make(map[*Node]string),
+	}
+	if e.Canonical {
+		d.canonicalDoc(node)
+	} else {
+		if e.docs > 0 {
+			d.WriteString("---\n")
+		}
+		d.node(node, 0, emitDoc)
+		d.WriteString("\n")
+	}
+
+	e.docs++
+	if _, e.err = io.WriteString(e.writer, d.String()); e.err != nil {
+		return e.err
+	}
+	return nil
+}
+
+// Emit writes node to writer as a single document; see Emitter.
+func Emit(writer io.Writer, node *Node) error {
+	return NewEmitter(writer).Emit(node)
+}
+
+func (e *Emitter) DocumentStart(mark Mark) {
+	e.builder = newNodeBuilder()
+	e.builder.allowRecursion = true
+}
+
+func (e *Emitter) DocumentEnd() {
+	if err := e.Emit(e.builder.Root()); err != nil {
+		panic(err)
+	}
+	e.builder = nil
+}
+
+func (e *Emitter) DocumentVersion(mark Mark, version Version) {
+	e.builder.DocumentVersion(mark, version)
+}
+
+func (e *Emitter) AnchorName(mark Mark, anchor Anchor, name string) {
+	e.builder.AnchorName(mark, anchor, name)
+}
+
+func (e *Emitter) Null(mark Mark, anchor Anchor) {
+	e.builder.Null(mark, anchor)
+}
+
+func (e *Emitter) Alias(mark Mark, anchor Anchor) {
+	e.builder.Alias(mark, anchor)
+}
+
+func (e *Emitter) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	e.builder.Scalar(mark, tag, anchor, value)
+}
+
+func (e *Emitter) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	e.builder.SequenceStart(mark, tag, anchor)
+}
+
+func (e *Emitter) SequenceEnd() {
+	e.builder.SequenceEnd()
+}
+
+func (e *Emitter) MapStart(mark Mark, tag string, anchor Anchor) {
+	e.builder.MapStart(mark, tag, anchor)
+}
+
+func (e *Emitter) MapEnd() {
+	e.builder.MapEnd()
+}
+
+// where a node is written: at the top of a document, after "key:", or
+// after "- " (or "? ")
+type emitContext int
+
+const (
+	emitDoc emitContext = iota
+	emitValue
+	emitItem
+)
+
+type docEmitter struct {
+	strings.Builder
+	indent    int
+	sortKeys  bool
+	anchors   map[*Node]bool
+	ancestors map[*Node]bool
+
+	// canonical form: the number of occurrences of each node, and the
+	// anchors given to those occurring more than once
+	refs  map[*Node]int
+	names map[*Node]string
+}
+
+// node writes n, leaving the cursor at the end of its last line. For a
+// value or item, indent is the column of the map or sequence it is in.
+func (d *docEmitter) node(n *Node, indent int, ctx emitContext) {
+	if len(n.Anchor) > 0 && d.anchors[n] {
+		d.WriteString(ctx.sep() + "*" + n.Anchor)
+		return
+	} else if d.ancestors[n] {
+		panic(&ParseError{n.Mark, ERR_RECURSIVE_ALIAS + "(node without an anchor)"})
+	}
+
+	props := ""
+	if len(n.Anchor) > 0 {
+		props = "&" + n.Anchor
+		d.anchors[n] = true
+	}
+	if tag := emitTag(n); len(tag) > 0 {
+		props = strings.TrimPrefix(props+" "+tag, " ")
+	}
+
+	// the column block content below this node starts at
+	child := indent + d.indent
+	switch ctx {
+	case emitDoc:
+		child = 0
+	case emitItem:
+		child = indent + 2
+	}
+
+	if n.Len() == 0 || n.Type != NODE_SEQUENCE && n.Type != NODE_MAP {
+		text := emitScalar(n, max(child, d.indent))
+		if len(props) > 0 {
+			text = props + " " + text
+		}
+		d.WriteString(ctx.sep() + text)
+		return
+	}
+
+	d.ancestors[n] = true
+	defer delete(d.ancestors, n)
+
+	// a collection starts on the next line unless it can begin right after
+	// the "- " of an item or at the top of the document
+	inline := len(props) == 0 && ctx != emitValue
+	if len(props) > 0 || inline {
+		d.WriteString(ctx.sep() + props)
+	}
+
+	if n.Type == NODE_SEQUENCE {
+		for i, item := range n.Children {
+			d.newline(child, i == 0 && inline)
+			d.WriteString("-")
+			d.node(item, child, emitItem)
+		}
+		return
+	}
+
+	for i, pair := range d.pairs(n) {
+		d.newline(child, i == 0 && inline)
+		if key, ok := d.simpleKey(pair.Key); ok {
+			d.WriteString(key + ":")
+		} else {
+			d.WriteString("?")
+			d.node(pair.Key, child, emitItem)
+			d.newline(child, false)
+			d.WriteString(":")
+		}
+		d.node(pair.Value, child, emitValue)
+	}
+}
+
+// newline starts a line indented to column, unless the content goes on
+// the current line.
+func (d *docEmitter) newline(column int, inline bool) {
+	if !inline {
+		d.WriteString("\n" + strings.Repeat(" ", column))
+	}
+}
+
+func (ctx emitContext) sep() string {
+	if ctx == emitDoc {
+		return ""
+	}
+	return " "
+}
+
+// simpleKey renders a key that fits on the "key:" line.
+func (d *docEmitter) simpleKey(key *Node) (string, bool) {
+	if len(key.Anchor) > 0 && d.anchors[key] {
+		// the space keeps the colon out of the alias name
+		return "*" + key.Anchor + " ", true
+	} else if key.Type == NODE_SEQUENCE || key.Type == NODE_MAP {
+		if key.Len() > 0 {
+			return "", false
+		}
+	}
+
+	text := emitScalar(key, 0)
+	if strings.Contains(text, "\n") {
+		return "", false
+	}
+
+	props := ""
+	if len(key.Anchor) > 0 {
+		props = "&" + key.Anchor + " "
+	}
+	if tag := emitTag(key); len(tag) > 0 {
+		props += tag + " "
+	}
+
+	// the scanner only looks this far back for the colon of a simple key
+	if len(props)+len(text) >= maxSimpleKeyLength {
+		return "", false
+	}
+	if len(key.Anchor) > 0 {
+		d.anchors[key] = true
+	}
+	return props + text, true
+}
+
+// emitTag returns the tag to write for a node, in shorthand where possible,
+// or "" for a non-specific tag.
+func emitTag(n *Node) string {
+	switch tag := n.Tag; {
+	case len(tag) == 0 || tag == "?" || tag == "!":
+		return ""
+	case strings.HasPrefix(tag, "tag:yaml.org,2002:") && isTagSuffix(strings.TrimPrefix(tag, "tag:yaml.org,2002:")):
+		return "!!" + strings.TrimPrefix(tag, "tag:yaml.org,2002:")
+	case strings.HasPrefix(tag, "!") && isTagSuffix(tag[1:]):
+		return tag
+	default:
+		return "!<" + tag + ">"
+	}
+}
+
+// emitScalar renders a scalar, null or empty collection. Block scalars are
+// indented to column.
+func emitScalar(n *Node, column int) string {
+	switch n.Type {
+	case NODE_SEQUENCE:
+		return "[]"
+	case NODE_MAP:
+		return "{}"
+	case NODE_SCALAR:
+	default:
+		return "null"
+	}
+
+	value := n.Value
+	switch n.Tag {
+	case "?", "":
+		if len(value) == 0 {
+			return "null"
+		} else if isPlainSafe(value) {
+			return value
+		}
+	case "!":
+		if isPlainSafe(value) && ResolveTag("?", value) == TAG_STR {
+			return value
+		}
+	default:
+		// the tag is written, so the text needn't resolve to anything
+		if isPlainSafe(value) {
+			return value
+		}
+	}
+
+	if text, ok := literalBlock(value, column); ok {
+		return text
+	}
+	return doubleQuote(value)
+}
+
+// literalBlock renders a multi-line string as a literal block scalar with
+// its content indented to column.
+func literalBlock(value string, column int) (string, bool) {
+	body := strings.TrimSuffix(value, "\n")
+	if !strings.Contains(body, "\n") || body[0] == ' ' || body[0] == '\n' || !canSingleQuote(strings.ReplaceAll(body, "\n", "")) {
+		return "", false
+	}
+
+	chomp := "+"
+	if !strings.HasSuffix(value, "\n") {
+		chomp = "-"
+	} else if !strings.HasSuffix(body, "\n") {
+		chomp = ""
+	}
+
+	text := "|" + chomp
+	for _, line := range strings.Split(body, "\n") {
+		text += "\n"
+		if len(line) > 0 {
+			text += strings.Repeat(" ", column) + line
+		}
+	}
+	return text, true
+}
@@ -4,12 +4,15 @@ const (
 	ERR_YAML_DIRECTIVE_ARGS     = "YAML directives must have exactly one argument"
 	ERR_YAML_VERSION            = "bad YAML version: "
 	ERR_YAML_MAJOR_VERSION      = "YAML major version too large"
+	ERR_YAML_MINOR_VERSION      = "YAML minor version too large; reading as YAML 1.2"
 	ERR_REPEATED_YAML_DIRECTIVE = "repeated YAML directive"
 	ERR_TAG_DIRECTIVE_ARGS      = "TAG directives must have exactly two arguments"
 	ERR_REPEATED_TAG_DIRECTIVE  = "repeated TAG directive"
 	ERR_CHAR_IN_TAG_HANDLE      = "illegal character found while scanning tag handle"
+	ERR_CHAR_IN_TAG_PREFIX      = "illegal character found in tag prefix"
 	ERR_TAG_WITH_NO_SUFFIX      = "tag handle with no suffix"
 	ERR_END_OF_VERBATIM_TAG     = "end of verbatim tag not found"
+	ERR_END_OF_DOC              = "end of document not found"
 	ERR_END_OF_MAP              = "end of map not found"
 	ERR_END_OF_MAP_FLOW         = "end of map flow not found"
 	ERR_END_OF_SEQ              = "end of sequence not found"
@@ -37,7 +40,8 @@ const (
 	ERR_ZERO_INDENT_IN_BLOCK    = "cannot set zero indentation for a block scalar"
 	ERR_CHAR_IN_BLOCK           = "unexpected character in block scalar"
 	ERR_AMBIGUOUS_ANCHOR        = "cannot assign the same alias to multiple nodes"
-	ERR_UNKNOWN_ANCHOR          = "the referenced anchor is not defined"
+	ERR_UNKNOWN_ANCHOR          = "the referenced anchor is not defined: "
+	ERR_RECURSIVE_ALIAS         = "alias refers to an ancestor of itself: "
 
 	ERR_INVALID_NODE    = "invalid node; this may result from using a map iterator as a sequence iterator, or vice-versa"
 	ERR_INVALID_SCALAR  = "invalid scalar"
@@ -56,4 +60,36 @@ const (
 	ERR_INVALID_ALIAS       = "invalid alias"
 	ERR_INVALID_TAG         = "invalid tag"
 	ERR_BAD_FILE            = "bad file"
+
+	ERR_QUERY_UNEXPECTED_END = "unexpected end of query"
+	ERR_QUERY_UNEXPECTED     = "unexpected character in query: "
+	ERR_QUERY_UNCLOSED_QUOTE = "unclosed quote in query"
+	ERR_QUERY_BAD_INDEX      = "bad index in query: "
+	ERR_QUERY_COMPARISON     = "a filter literal must be compared with a path"
+
+	ERR_EDIT_NOT_SCALAR = "only scalars can be edited in place"
+	ERR_EDIT_NOT_MAP    = "cannot set a key on a non-map"
+	ERR_EDIT_NOT_SEQ    = "appending to a non-sequence"
+	ERR_EDIT_NO_SOURCE  = "node has no source text to edit"
+
+	ERR_JSON_KEY       = "JSON object keys must be strings"
+	ERR_JSON_NONFINITE = "JSON has no notation for "
+	ERR_JSON_EXPANSION = "aliases expand to too much JSON for the size of the input"
+
+	ERR_PATCH_NOT_SEQ         = "a JSON patch must be a sequence of operations"
+	ERR_PATCH_NOT_MAP         = "a patch operation must be a map"
+	ERR_PATCH_MISSING_FIELD   = "patch operation without a field: "
+	ERR_PATCH_FIELD_TYPE      = "patch operation field must be a scalar: "
+	ERR_PATCH_UNKNOWN_OP      = "unknown patch operation: "
+	ERR_PATCH_BAD_POINTER     = "bad JSON pointer: "
+	ERR_PATCH_NO_KEY          = "key not found: "
+	ERR_PATCH_NOT_CONTAINER   = "path goes through a scalar at: "
+	ERR_PATCH_BAD_INDEX       = "bad sequence index: "
+	ERR_PATCH_INDEX_RANGE     = "sequence index out of range: "
+	ERR_PATCH_REMOVE_ROOT     = "cannot remove the whole document"
+	ERR_PATCH_MOVE_INTO_CHILD = "cannot move a node into one of its children"
+	ERR_PATCH_TEST_FAILED     = "test failed"
+
+	ERR_SCHEMA_PATTERN = "bad pattern in schema: "
+	ERR_SCHEMA_REF     = "unresolvable $ref in schema: "
 )
@@ -0,0 +1,295 @@
+package yaml
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EventEmitter is an EventHandler that writes the documents it receives as
+// YAML while their events arrive, in the block style of Emitter, so a
+// Parser can re-serialise a stream of any size in constant memory:
+//
+//	emitter := yaml.NewEventEmitter(os.Stdout)
+//	for {
+//		ok, err := parser.HandleNextDocument(emitter)
+//		...
+//	}
+//
+// Tags and anchors are written as they come; anchors are named as reported
+// through AnchorNameHandler, or by number. Plain scalars of YAML 1.1
+// documents are rewritten to mean the same in YAML 1.2. Scalars that weren't
+// plain in the input are quoted (or written as literal block scalars when
+// they span lines), and plain ones are written plain where that reads back
+// the same. Since nothing is held back, empty collections in map keys are
+// written after "?", and Emitter's canonical form and key sorting aren't
+// available.
+//
+// Each document is flushed to the writer once it ends; an error writing it
+// fails the HandleNextDocument call.
+type EventEmitter struct {
+	writer *bufio.Writer
+	err    error
+	docs   int
+	names  map[Anchor]string
+	frames []eventEmitterFrame
+
+	// the YAML version of the document, whose plain scalars are rewritten
+	// to their YAML 1.2 meaning
+	version Version
+
+	// Indent is the number of spaces block collections nested in a map are
+	// indented by.
+	Indent int
+}
+
+// eventEmitterFrame is a collection being written.
+type eventEmitterFrame struct {
+	isMap  bool
+	ctx    emitContext // where the collection is written
+	props  string      // its anchor and tag
+	column int         // its entries or items start at
+	inline bool        // its first entry or item goes on the current line
+	open   bool        // its props, or the separator before it, are written
+	count  int         // of nodes written in it, keys and values alike
+
+	// the current key is written after "?", and needs a ":" line
+	complexKey bool
+}
+
+func NewEventEmitter(writer io.Writer) *EventEmitter {
+	return &EventEmitter{writer: bufio.NewWriter(writer), Indent: 2}
+}
+
+func (e *EventEmitter) write(text string) {
+	if e.err == nil {
+		_, e.err = e.writer.WriteString(text)
+	}
+}
+
+// newline starts a line indented to column, unless the content goes on
+// the current line.
+func (e *EventEmitter) newline(column int, inline bool) {
+	if !inline {
+		e.write("\n" + strings.Repeat(" ", column))
+	}
+}
+
+func (e *EventEmitter) top() *eventEmitterFrame {
+	if len(e.frames) == 0 {
+		return nil
+	}
+	return &e.frames[len(e.frames)-1]
+}
+
+// props returns the anchor and tag to write before a node.
+func (e *EventEmitter) props(tag string, anchor Anchor) string {
+	props := ""
+	if anchor != NullAnchor {
+		props = "&" + e.anchorName(anchor)
+	}
+	if tag := emitTag(&Node{Tag: tag}); len(tag) > 0 {
+		props = strings.TrimPrefix(props+" "+tag, " ")
+	}
+	return props
+}
+
+func (e *EventEmitter) anchorName(anchor Anchor) string {
+	if name, ok := e.names[anchor]; ok {
+		return name
+	}
+	return strconv.Itoa(int(anchor))
+}
+
+// childColumn returns the column the block content of a node written at
+// indent in ctx starts at.
+func (e *EventEmitter) childColumn(indent int, ctx emitContext) int {
+	switch ctx {
+	case emitDoc:
+		return 0
+	case emitItem:
+		return indent + 2
+	}
+	return indent + max(e.Indent, 1)
+}
+
+// next writes what goes before the next node of the innermost collection,
+// and returns the indent and context to write the node in. A map key that
+// fits on the "key:" line is written straight away when given as key, and
+// next then reports it done.
+func (e *EventEmitter) next(key string, isSimple bool) (indent int, ctx emitContext, done bool) {
+	f := e.top()
+	if f == nil {
+		return 0, emitDoc, false
+	}
+
+	if !f.open {
+		f.open = true
+		if len(f.props) > 0 || f.inline {
+			e.write(f.ctx.sep() + f.props)
+		}
+	}
+
+	first := f.count == 0
+	switch {
+	case !f.isMap:
+		e.newline(f.column, first && f.inline)
+		e.write("-")
+		return f.column, emitItem, false
+	case f.count%2 == 1:
+		return f.column, emitValue, false
+	}
+
+	e.newline(f.column, first && f.inline)
+	if isSimple && !strings.Contains(key, "\n") && len(key) < maxSimpleKeyLength {
+		e.write(key + ":")
+		e.done()
+		return 0, 0, true
+	}
+	e.write("?")
+	f.complexKey = true
+	return f.column, emitItem, false
+}
+
+// done moves past a node of the innermost collection.
+func (e *EventEmitter) done() {
+	f := e.top()
+	if f == nil {
+		return
+	}
+
+	f.count++
+	if f.complexKey {
+		f.complexKey = false
+		e.newline(f.column, false)
+		e.write(":")
+	}
+}
+
+// leaf writes a scalar, null or alias, with text rendering it with its
+// block content at a column.
+func (e *EventEmitter) leaf(props string, text func(column int) string) {
+	key := text(0)
+	if len(props) > 0 {
+		key = props + " " + key
+	}
+
+	indent, ctx, done := e.next(key, true)
+	if done {
+		return
+	}
+
+	value := text(max(e.childColumn(indent, ctx), max(e.Indent, 1)))
+	if len(props) > 0 {
+		value = props + " " + value
+	}
+	e.write(ctx.sep() + value)
+	e.done()
+}
+
+// scalarText renders a scalar, keeping quoted scalars quoted.
+func scalarText(tag, value string, column int) string {
+	if tag == "!" {
+		if text, ok := literalBlock(value, column); ok {
+			return text
+		} else if canSingleQuote(value) {
+			return singleQuote(value)
+		}
+		return doubleQuote(value)
+	}
+	return emitScalar(&Node{Type: NODE_SCALAR, Tag: tag, Value: value}, column)
+}
+
+func (e *EventEmitter) AnchorName(mark Mark, anchor Anchor, name string) {
+	e.names[anchor] = name
+}
+
+func (e *EventEmitter) DocumentStart(mark Mark) {
+	if e.docs > 0 {
+		e.write("---\n")
+	}
+	if e.names == nil {
+		e.names = make(map[Anchor]string)
+	}
+	clear(e.names)
+	e.frames = e.frames[:0]
+	e.version = Version{}
+}
+
+func (e *EventEmitter) DocumentVersion(mark Mark, version Version) {
+	e.version = version
+}
+
+func (e *EventEmitter) DocumentEnd() {
+	e.write("\n")
+	e.docs++
+	if e.err == nil {
+		e.err = e.writer.Flush()
+	}
+	if e.err != nil {
+		panic(e.err)
+	}
+}
+
+func (e *EventEmitter) Null(mark Mark, anchor Anchor) {
+	e.leaf(e.props("", anchor), func(int) string { return "null" })
+}
+
+func (e *EventEmitter) Alias(mark Mark, anchor Anchor) {
+	alias := "*" + e.anchorName(anchor)
+
+	// the space keeps a key's colon out of the alias name
+	if _, ctx, done := e.next(alias+" ", true); !done {
+		e.write(ctx.sep() + alias)
+		e.done()
+	}
+}
+
+func (e *EventEmitter) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	tag, value = coreScalar(tag, value, e.version)
+	e.leaf(e.props(tag, anchor), func(column int) string {
+		return scalarText(tag, value, column)
+	})
+}
+
+func (e *EventEmitter) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	e.start(false, e.props(tag, anchor))
+}
+
+func (e *EventEmitter) SequenceEnd() {
+	e.end("[]")
+}
+
+func (e *EventEmitter) MapStart(mark Mark, tag string, anchor Anchor) {
+	e.start(true, e.props(tag, anchor))
+}
+
+func (e *EventEmitter) MapEnd() {
+	e.end("{}")
+}
+
+func (e *EventEmitter) start(isMap bool, props string) {
+	indent, ctx, _ := e.next("", false)
+	e.frames = append(e.frames, eventEmitterFrame{
+		isMap:  isMap,
+		ctx:    ctx,
+		props:  props,
+		column: e.childColumn(indent, ctx),
+		inline: len(props) == 0 && ctx != emitValue,
+	})
+}
+
+// end closes the innermost collection, writing it as empty if nothing in
+// it was.
+func (e *EventEmitter) end(empty string) {
+	f := e.frames[len(e.frames)-1]
+	e.frames = e.frames[:len(e.frames)-1]
+	if !f.open {
+		if len(f.props) > 0 {
+			empty = f.props + " " + empty
+		}
+		e.write(f.ctx.sep() + empty)
+	}
+	e.done()
+}
@@ -4,7 +4,7 @@ type EventHandler interface {
 	DocumentStart(mark Mark)
 	DocumentEnd()
 
-	Null(mark Mark, anchor Anchor)
+	Null(mark Mark, anchor Anchor) // an empty node; a plain null is a Scalar
 	Alias(mark Mark, anchor Anchor)
 	Scalar(mark Mark, tag string, anchor Anchor, value string)
 
@@ -14,3 +14,27 @@ type EventHandler interface {
 	MapStart(mark Mark, tag string, anchor Anchor)
 	MapEnd()
 }
+
+// AnchorNameHandler may be implemented by an EventHandler that wants the
+// names behind Anchor values. AnchorName is called when an anchor is
+// defined, right before the event for the node it is attached to.
+type AnchorNameHandler interface {
+	AnchorName(mark Mark, anchor Anchor, name string)
+}
+
+// VersionHandler may be implemented by an EventHandler that wants the YAML
+// version each document is read under, which decides what its plain
+// scalars mean (see ResolveTagVersion). DocumentVersion is called right
+// after DocumentStart.
+type VersionHandler interface {
+	DocumentVersion(mark Mark, version Version)
+}
+
+// ScalarBytesHandler may be implemented by an EventHandler that wants scalar
+// values as byte slices, to avoid converting them; ScalarBytes is then called
+// instead of Scalar. A value that appears verbatim in the input shares the
+// scanner's read buffer, which is never overwritten, so the slice stays valid
+// but must not be modified.
+type ScalarBytesHandler interface {
+	ScalarBytes(mark Mark, tag string, anchor Anchor, value []byte)
+}
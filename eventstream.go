@@ -0,0 +1,332 @@
+package yaml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EventWriter is an EventHandler that writes the events it receives in the
+// yaml-test-suite event notation, one event per line:
+//
+//	+STR
+//	+DOC
+//	+MAP &defaults <tag:yaml.org,2002:map>
+//	=VAL :key
+//	=VAL "quoted value
+//	=ALI *defaults
+//	-MAP
+//	-DOC
+//	-STR
+//
+// Plain scalars are written with the ':' indicator and all other scalars with
+// '"'; the events don't say which quoting or block style was used. Anchors
+// are written by name, or by number when the names are not reported (see
+// AnchorNameHandler).
+type EventWriter struct {
+	writer  io.Writer
+	err     error
+	started bool
+	anchors map[Anchor]string
+}
+
+func NewEventWriter(writer io.Writer) *EventWriter {
+	return &EventWriter{writer: writer}
+}
+
+// Close ends the stream and returns the first error encountered while
+// writing.
+func (e *EventWriter) Close() error {
+	e.start()
+	e.writeLine("-STR")
+	return e.err
+}
+
+func (e *EventWriter) start() {
+	if !e.started {
+		e.started = true
+		e.writeLine("+STR")
+	}
+}
+
+func (e *EventWriter) writeLine(line string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.writer, line+"\n")
+	}
+}
+
+func (e *EventWriter) writeEvent(kind string, tag string, anchor Anchor, value *string) {
+	line := kind
+	if anchor != NullAnchor {
+		line += " &" + e.anchorName(anchor)
+	}
+
+	// a scalar's "!" tells its style, but a collection's is the explicit
+	// non-specific tag
+	style := ":"
+	switch {
+	case tag == "?" || tag == "":
+	case tag == "!" && value != nil:
+		style = "\""
+	default:
+		line += " <" + tag + ">"
+	}
+
+	if value != nil {
+		line += " " + style + escapeEventValue(*value)
+	}
+	e.writeLine(line)
+}
+
+func (e *EventWriter) anchorName(anchor Anchor) string {
+	if name, ok := e.anchors[anchor]; ok {
+		return name
+	}
+	return strconv.Itoa(int(anchor))
+}
+
+func (e *EventWriter) AnchorName(mark Mark, anchor Anchor, name string) {
+	if e.anchors == nil {
+		e.anchors = make(map[Anchor]string)
+	}
+	e.anchors[anchor] = name
+}
+
+func (e *EventWriter) DocumentStart(mark Mark) {
+	e.start()
+	clear(e.anchors)
+	e.writeLine("+DOC")
+}
+
+func (e *EventWriter) DocumentEnd() {
+	e.writeLine("-DOC")
+}
+
+func (e *EventWriter) Null(mark Mark, anchor Anchor) {
+	empty := ""
+	e.writeEvent("=VAL", "", anchor, &empty)
+}
+
+func (e *EventWriter) Alias(mark Mark, anchor Anchor) {
+	e.writeLine("=ALI *" + e.anchorName(anchor))
+}
+
+func (e *EventWriter) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	e.writeEvent("=VAL", tag, anchor, &value)
+}
+
+func (e *EventWriter) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	e.writeEvent("+SEQ", tag, anchor, nil)
+}
+
+func (e *EventWriter) SequenceEnd() {
+	e.writeLine("-SEQ")
+}
+
+func (e *EventWriter) MapStart(mark Mark, tag string, anchor Anchor) {
+	e.writeEvent("+MAP", tag, anchor, nil)
+}
+
+func (e *EventWriter) MapEnd() {
+	e.writeLine("-MAP")
+}
+
+var eventValueEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\x00", "\\0",
+	"\b", "\\b",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\t", "\\t",
+)
+
+var eventValueUnescaper = strings.NewReplacer(
+	"\\\\", "\\",
+	"\\0", "\x00",
+	"\\b", "\b",
+	"\\n", "\n",
+	"\\r", "\r",
+	"\\t", "\t",
+)
+
+func escapeEventValue(value string) string {
+	return eventValueEscaper.Replace(value)
+}
+
+func unescapeEventValue(value string) string {
+	return eventValueUnescaper.Replace(value)
+}
+
+// EventReader replays a stream written in the yaml-test-suite event notation
+// (such as EventWriter output) into an EventHandler, a document at a time,
+// just like a Parser. The marks it reports point into the event text.
+//
+// Scalar styles map onto the parser's non-specific tags: plain scalars get
+// "?" and all others "!". An empty, untagged plain scalar is reported as a
+// null. Anchors are numbered per document in order of appearance, and their
+// names are passed on to handlers implementing AnchorNameHandler.
+type EventReader struct {
+	lines      *bufio.Scanner
+	mark       Mark
+	next       Mark
+	anchors    map[string]Anchor
+	curranchor Anchor
+	ended      bool
+}
+
+func NewEventReader(reader io.Reader) *EventReader {
+	return &EventReader{lines: bufio.NewScanner(reader)}
+}
+
+func (e *EventReader) HandleNextDocument(evtHandler EventHandler) (success bool, err error) {
+	// Handle malformed event panics.
+	defer func() {
+		if r := recover(); r != nil {
+			success = false
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("yamlgo: %v", r)
+			}
+		}
+	}()
+
+	depth := 0
+	for !e.ended {
+		line, ok := e.readLine()
+		if !ok {
+			if depth > 0 {
+				panic(&ParseError{e.mark, "unexpected end of event stream"})
+			}
+			return
+		}
+
+		kind, rest := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			kind, rest = line[:i], line[i+1:]
+		}
+
+		if depth == 0 && kind != "+STR" && kind != "-STR" && kind != "+DOC" {
+			panic(&ParseError{e.mark, "expected a document start, found " + kind})
+		}
+
+		switch kind {
+		case "+STR":
+		case "-STR":
+			e.ended = true
+		case "+DOC":
+			if depth > 0 {
+				panic(&ParseError{e.mark, "document start inside a document"})
+			}
+			e.anchors = make(map[string]Anchor)
+			e.curranchor = NullAnchor
+			evtHandler.DocumentStart(e.mark)
+			depth++
+		case "-DOC":
+			evtHandler.DocumentEnd()
+			depth--
+		case "+MAP", "+SEQ":
+			rest = strings.TrimPrefix(strings.TrimPrefix(rest, "{}"), "[]")
+			tag, anchor, _ := e.parseProperties(evtHandler, strings.TrimSpace(rest))
+			if len(tag) == 0 {
+				tag = "?"
+			}
+			if kind == "+MAP" {
+				evtHandler.MapStart(e.mark, tag, anchor)
+			} else {
+				evtHandler.SequenceStart(e.mark, tag, anchor)
+			}
+			depth++
+		case "-MAP":
+			evtHandler.MapEnd()
+			depth--
+		case "-SEQ":
+			evtHandler.SequenceEnd()
+			depth--
+		case "=ALI":
+			name := strings.TrimPrefix(rest, "*")
+			anchor, ok := e.anchors[name]
+			if !ok {
+				panic(newAliasError(e.mark, name))
+			}
+			evtHandler.Alias(e.mark, anchor)
+		case "=VAL":
+			tag, anchor, value := e.parseProperties(evtHandler, rest)
+			if len(value) == 0 {
+				panic(&ParseError{e.mark, "scalar without a style indicator"})
+			}
+
+			style, value := value[0], unescapeEventValue(value[1:])
+			if len(tag) == 0 {
+				if style != ':' {
+					tag = "!"
+				} else if len(value) == 0 {
+					evtHandler.Null(e.mark, anchor)
+					break
+				} else {
+					tag = "?"
+				}
+			}
+			evtHandler.Scalar(e.mark, tag, anchor, value)
+		default:
+			panic(&ParseError{e.mark, "unknown event " + kind})
+		}
+
+		if depth < 0 {
+			panic(&ParseError{e.mark, "unbalanced " + kind})
+		}
+		if kind == "-DOC" {
+			return true, nil
+		}
+	}
+	return
+}
+
+func (e *EventReader) readLine() (string, bool) {
+	for e.lines.Scan() {
+		e.mark = e.next
+		e.next.Pos += len(e.lines.Bytes()) + 1
+		e.next.Line++
+
+		if line := strings.TrimRight(e.lines.Text(), "\r"); len(strings.TrimSpace(line)) > 0 {
+			return line, true
+		}
+	}
+
+	if err := e.lines.Err(); err != nil {
+		panic(&ParseError{e.mark, err.Error()})
+	}
+	return "", false
+}
+
+// parseProperties splits the anchor and tag off the front of an event's
+// arguments and returns whatever follows them.
+func (e *EventReader) parseProperties(evtHandler EventHandler, args string) (tag string, anchor Anchor, rest string) {
+	rest = args
+	for len(rest) > 0 {
+		var prop string
+		switch rest[0] {
+		case '&':
+			prop, rest = rest, ""
+			if i := strings.IndexByte(prop, ' '); i >= 0 {
+				prop, rest = prop[:i], prop[i+1:]
+			}
+			e.curranchor++
+			anchor = e.curranchor
+			e.anchors[prop[1:]] = anchor
+			if h, ok := evtHandler.(AnchorNameHandler); ok {
+				h.AnchorName(e.mark, anchor, prop[1:])
+			}
+		case '<':
+			i := strings.IndexByte(rest, '>')
+			if i < 0 {
+				panic(&ParseError{e.mark, ERR_INVALID_TAG})
+			}
+			tag, rest = rest[1:i], strings.TrimPrefix(rest[i+1:], " ")
+		default:
+			return
+		}
+	}
+	return
+}
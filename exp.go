@@ -0,0 +1,302 @@
+package yaml
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Character classes and lookahead expressions used by the scanner. yaml-cpp
+// builds these out of composable RegEx objects; here they are plain
+// functions over the stream so that matching never allocates.
+
+const (
+	key_DIRECTIVE      = '%'
+	key_FLOW_SEQ_START = '['
+	key_FLOW_SEQ_END   = ']'
+	key_FLOW_MAP_START = '{'
+	key_FLOW_MAP_END   = '}'
+	key_FLOW_ENTRY     = ','
+	key_ALIAS          = '*'
+	key_ANCHOR         = '&'
+	key_TAG            = '!'
+	key_LITERAL_SCALAR = '|'
+	key_FOLDED_SCALAR  = '>'
+	key_VERBATIM_START = '<'
+	key_VERBATIM_END   = '>'
+)
+
+func expEOF(in *stream) bool {
+	return !in.ok()
+}
+
+func expBlank(ch int) bool {
+	return ch == ' ' || ch == '\t'
+}
+
+func expIsBreak(ch int) bool {
+	return ch == '\n' || ch == '\r'
+}
+
+func expBlankOrBreak(ch int) bool {
+	return expBlank(ch) || expIsBreak(ch)
+}
+
+func expBlankOrBreakOrEOF(ch int) bool {
+	return ch < 0 || expBlankOrBreak(ch)
+}
+
+func expDigit(ch int) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func expHex(ch int) bool {
+	return expDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func expWord(ch int) bool {
+	return expDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '-'
+}
+
+func expOneOf(ch int, set string) bool {
+	if ch < 0 {
+		return false
+	}
+	for i := 0; i < len(set); i++ {
+		if int(set[i]) == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// expBreak returns the length of the line break at offset i, or 0.
+func expBreak(in *stream, i int) int {
+	switch in.at(i) {
+	case '\n':
+		return 1
+	case '\r':
+		if in.at(i+1) == '\n' {
+			return 2
+		}
+		return 1
+	case 0xC2:
+		// NEL, in YAML 1.1
+		if in.yaml11 && in.at(i+1) == 0x85 {
+			return 2
+		}
+	}
+	return 0
+}
+
+func expDocIndicator(in *stream, ch byte) bool {
+	return in.at(0) == int(ch) && in.at(1) == int(ch) && in.at(2) == int(ch) && expBlankOrBreakOrEOF(in.at(3))
+}
+
+func expDocStart(in *stream) bool {
+	return expDocIndicator(in, '-')
+}
+
+func expDocEnd(in *stream) bool {
+	return expDocIndicator(in, '.')
+}
+
+func expBlockEntry(in *stream) bool {
+	return in.at(0) == '-' && expBlankOrBreakOrEOF(in.at(1))
+}
+
+func expKey(in *stream) bool {
+	return in.at(0) == '?' && expBlankOrBreakOrEOF(in.at(1))
+}
+
+func expValue(in *stream) bool {
+	return in.at(0) == ':' && expBlankOrBreakOrEOF(in.at(1))
+}
+
+func expValueInFlow(in *stream) bool {
+	return in.at(0) == ':' && (expBlankOrBreakOrEOF(in.at(1)) || expOneOf(in.at(1), ",]}"))
+}
+
+func expValueInJSONFlow(in *stream) bool {
+	return in.at(0) == ':'
+}
+
+func expComment(in *stream) bool {
+	return in.at(0) == '#'
+}
+
+func expAnchor(ch int) bool {
+	return ch >= 0 && !expBlankOrBreak(ch) && !expOneOf(ch, "[]{},")
+}
+
+func expAnchorEnd(ch int) bool {
+	return expBlankOrBreak(ch) || expOneOf(ch, "?:,]}%@`")
+}
+
+// expEscapedHex returns 3 if a %-escaped hex pair starts at offset i.
+func expEscapedHex(in *stream, i int) int {
+	if in.at(i) == '%' && expHex(in.at(i+1)) && expHex(in.at(i+2)) {
+		return 3
+	}
+	return 0
+}
+
+// expURI returns the length of the URI character at the head of the stream.
+func expURI(in *stream) int {
+	if ch := in.at(0); expWord(ch) || expOneOf(ch, "#;/?:@&=+$,_.!~*'()[]") {
+		return 1
+	}
+	return expEscapedHex(in, 0)
+}
+
+// isURI reports whether s is made of URI characters only, as a %TAG prefix
+// must be.
+func isURI(s string) bool {
+	return isMadeOf(s, "#;/?:@&=+$,_.!~*'()[]")
+}
+
+// isTagSuffix reports whether s can follow a tag handle.
+func isTagSuffix(s string) bool {
+	return len(s) > 0 && isMadeOf(s, "#;/?:@&=+$_.~*'()")
+}
+
+// isMadeOf reports whether s is made of word characters, characters in set
+// and escaped hex bytes.
+func isMadeOf(s, set string) bool {
+	for i := 0; i < len(s); i++ {
+		switch ch := int(s[i]); {
+		case expWord(ch) || expOneOf(ch, set):
+		case ch == '%' && i+2 < len(s) && expHex(int(s[i+1])) && expHex(int(s[i+2])):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// expTag returns the length of the tag character at the head of the stream.
+func expTag(in *stream) int {
+	if ch := in.at(0); expWord(ch) || expOneOf(ch, "#;/?:@&=+$_.~*'()") {
+		return 1
+	}
+	return expEscapedHex(in, 0)
+}
+
+func expPlainScalar(in *stream) bool {
+	ch := in.at(0)
+	if expBlankOrBreakOrEOF(ch) || expOneOf(ch, ",[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	return !expOneOf(ch, "-?:") || !expBlankOrBreakOrEOF(in.at(1))
+}
+
+func expPlainScalarInFlow(in *stream) bool {
+	ch := in.at(0)
+	if expBlankOrBreakOrEOF(ch) || expOneOf(ch, "?,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	return !expOneOf(ch, "-:") || !expBlankOrBreakOrEOF(in.at(1))
+}
+
+// expScalarEnd reports whether a plain scalar in the block context ends at
+// the head of the stream.
+func expScalarEnd(in *stream) bool {
+	if expValue(in) {
+		return true
+	}
+	return expBlankOrBreak(in.at(0)) && in.at(1) == '#'
+}
+
+// expScalarEndInFlow reports whether a plain scalar in the flow context ends
+// at the head of the stream.
+func expScalarEndInFlow(in *stream) bool {
+	if expValueInFlow(in) || expOneOf(in.at(0), ",[]{}") {
+		return true
+	}
+	return expBlankOrBreak(in.at(0)) && in.at(1) == '#'
+}
+
+func expEscBreak(in *stream) bool {
+	return in.at(0) == '\\' && expBreak(in, 1) > 0
+}
+
+// expEscape consumes an escape sequence (the escape character included) and
+// returns the text it stands for.
+func expEscape(in *stream) string {
+	escape := in.get()
+	if !in.ok() {
+		panic(&ParseError{in.mark, ERR_EOF_IN_SCALAR})
+	}
+	ch := in.get()
+
+	// first do single quote, since it's easier
+	if escape == '\'' && ch == '\'' {
+		return "'"
+	}
+
+	switch ch {
+	case '0':
+		return "\x00"
+	case 'a':
+		return "\x07"
+	case 'b':
+		return "\x08"
+	case 't', '\t':
+		return "\x09"
+	case 'n':
+		return "\x0A"
+	case 'v':
+		return "\x0B"
+	case 'f':
+		return "\x0C"
+	case 'r':
+		return "\x0D"
+	case 'e':
+		return "\x1B"
+	case ' ':
+		return "\x20"
+	case '"':
+		return "\""
+	case '\'':
+		return "'"
+	case '\\':
+		return "\\"
+	case '/':
+		// added in YAML 1.2, for JSON compatibility
+		if !in.yaml11 {
+			return "/"
+		}
+	case 'N':
+		return "\u0085"
+	case '_':
+		return "\u00A0"
+	case 'L':
+		return "\u2028"
+	case 'P':
+		return "\u2029"
+	case 'x':
+		return expEscapeCode(in, 2)
+	case 'u':
+		return expEscapeCode(in, 4)
+	case 'U':
+		return expEscapeCode(in, 8)
+	}
+
+	panic(&ParseError{in.mark, ERR_INVALID_ESCAPE + string(rune(ch))})
+}
+
+func expEscapeCode(in *stream, length int) string {
+	code := make([]byte, 0, length)
+	for i := 0; i < length; i++ {
+		if !expHex(in.at(0)) {
+			panic(&ParseError{in.mark, ERR_INVALID_HEX})
+		}
+		code = append(code, in.get())
+	}
+
+	value, _ := strconv.ParseUint(string(code), 16, 32)
+	if (value >= 0xD800 && value <= 0xDFFF) || value > utf8.MaxRune {
+		panic(&ParseError{in.mark, ERR_INVALID_UNICODE + string(code)})
+	}
+	return string(rune(value))
+}
@@ -0,0 +1,170 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzSeeds cover the syntax the scanner and parser branch on; the fuzzers
+// start from these and from the benchmark corpora.
+var fuzzSeeds = []string{
+	"",
+	"a: 1\nb: [x, y]\nc: {d: e}\n",
+	"- a\n- - b\n  - c\n- d: e\n  f: g\n",
+	"%YAML 1.2\n%TAG !e! tag:example.com,2000:\n--- !e!foo bar\n...\n",
+	"--- !!str x\n--- !<tag:yaml.org,2002:int> 1\n--- ! y\n",
+	"a: &x [1, 2]\nb: *x\nc: &y {k: v}\nd: *y\n",
+	"? complex key\n: value\n? [a, b]\n: {c: d}\n",
+	"lit: |+\n  keep\n\nfold: >-\n  folded\n  text\n\n",
+	"q: 'it''s'\nd: \"tab\\t\\u00e9\\x41\\\n  continued\"\n",
+	"[a, b: c, ? d : e, {f: g}]\n",
+	"a:\n  - b\n  -\n  - c: d\n    e:\n",
+	"plain\n  multi line\n\n  scalar\n",
+	"'unterminated\n",
+	"a: b: c\n",
+	"\xEF\xBB\xBF# bom\nkey: value\n",
+	"{a: 1,\n b: 2}\n",
+	"- &a a\n- *a\n- &b [*a]\n",
+	"a: !local x\nb: !!binary AAAA\n",
+	"%YAML 1.1\n---\na: yes\nb: 0755\nc: \"x\u0085y\\\u0085z\"\n",
+
+	// inputs the fuzzers have tripped on
+	"\"\\",
+	"  !00000",
+	",P",
+	"%TAG !e! tag:example.com,200[[d\x00:\n--- !e!f",
+	"? " + strings.Repeat("B", 1100),
+	"--- !<tag:yaml.org,2002:i!!>",
+	"? !f:ldo: |+\n  keep\n\n",
+}
+
+func addFuzzSeeds(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Add([]byte(benchConfig))
+}
+
+// checkFuzzError fails on errors that come from a bug rather than from bad
+// input: runtime errors, and panics with something other than an error.
+func checkFuzzError(t *testing.T, err error) {
+	var runtimeErr runtime.Error
+	if errors.As(err, &runtimeErr) || err != nil && strings.HasPrefix(err.Error(), "yamlgo: ") {
+		t.Fatalf("internal error: %v", err)
+	}
+}
+
+func FuzzTokens(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tokens := NewTokenStream(bytes.NewReader(data))
+		for tokens.Next() {
+			_ = tokens.Token().String()
+		}
+		checkFuzzError(t, tokens.Err())
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		writer := NewEventWriter(io.Discard)
+		parser := NewParser(bytes.NewReader(data))
+		for {
+			ok, err := parser.HandleNextDocument(writer)
+			checkFuzzError(t, err)
+			if !ok || err != nil {
+				break
+			}
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoder := NewDecoder(bytes.NewReader(data))
+		decoder.AllowRecursion = true
+		for {
+			_, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			}
+			checkFuzzError(t, err)
+			if err != nil {
+				break
+			}
+		}
+	})
+}
+
+// FuzzRoundTrip checks that emitting a document, from its Node or straight
+// from its events, and loading the result gives back the same document.
+// Only valid UTF-8 can round-trip, since the emitter writes text.
+func FuzzRoundTrip(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if !utf8.Valid(data) {
+			t.Skip()
+		}
+
+		docs, err := LoadAll(bytes.NewReader(data))
+		checkFuzzError(t, err)
+		if err != nil {
+			return
+		}
+
+		emits := map[string]func(out *bytes.Buffer) error{
+			"emitter": func(out *bytes.Buffer) error {
+				return emitAll(NewEmitter(out), docs)
+			},
+			"canonical emitter": func(out *bytes.Buffer) error {
+				emitter := NewEmitter(out)
+				emitter.Canonical = true
+				return emitAll(emitter, docs)
+			},
+			"event emitter": func(out *bytes.Buffer) error {
+				parser := NewParser(bytes.NewReader(data))
+				emitter := NewEventEmitter(out)
+				for {
+					if ok, err := parser.HandleNextDocument(emitter); !ok || err != nil {
+						return err
+					}
+				}
+			},
+		}
+
+		for name, emit := range emits {
+			var out bytes.Buffer
+			if err := emit(&out); err != nil {
+				t.Fatalf("emitting %q with the %s: %v", data, name, err)
+			}
+
+			again, err := LoadAll(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("reloading %q emitted from %q by the %s: %v", out.Bytes(), data, name, err)
+			} else if len(again) != len(docs) {
+				t.Fatalf("%d documents emitted as %q from %q, %d reloaded", len(docs), out.Bytes(), data, len(again))
+			}
+			for i := range docs {
+				if changes := Diff(docs[i], again[i]); len(changes) > 0 {
+					t.Fatalf("document %d of %q changed when emitted as %q: %v", i, data, out.Bytes(), changes[0])
+				}
+			}
+		}
+	})
+}
+
+func emitAll(emitter *Emitter, docs []*Node) error {
+	for _, doc := range docs {
+		if err := emitter.Emit(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+module github.com/chryan/yamlgo
+
+go 1.23
@@ -0,0 +1,348 @@
+package yaml
+
+// BaseHandler is an EventHandler that ignores every event. Embed it in a
+// handler to implement only the events of interest:
+//
+//	type scalarCounter struct {
+//		yaml.BaseHandler
+//		n int
+//	}
+//
+//	func (c *scalarCounter) Scalar(mark yaml.Mark, tag string, anchor yaml.Anchor, value string) {
+//		c.n++
+//	}
+type BaseHandler struct{}
+
+func (BaseHandler) DocumentStart(mark Mark)                                   {}
+func (BaseHandler) DocumentEnd()                                              {}
+func (BaseHandler) Null(mark Mark, anchor Anchor)                             {}
+func (BaseHandler) Alias(mark Mark, anchor Anchor)                            {}
+func (BaseHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {}
+func (BaseHandler) SequenceStart(mark Mark, tag string, anchor Anchor)        {}
+func (BaseHandler) SequenceEnd()                                              {}
+func (BaseHandler) MapStart(mark Mark, tag string, anchor Anchor)             {}
+func (BaseHandler) MapEnd()                                                   {}
+
+// anchorNameEvent is an AnchorName call held back by a handler.
+type anchorNameEvent struct {
+	mark   Mark
+	anchor Anchor
+	name   string
+}
+
+func passAnchorName(handler EventHandler, event anchorNameEvent) {
+	if h, ok := handler.(AnchorNameHandler); ok {
+		h.AnchorName(event.mark, event.anchor, event.name)
+	}
+}
+
+func passVersion(handler any, mark Mark, version Version) {
+	if h, ok := handler.(VersionHandler); ok {
+		h.DocumentVersion(mark, version)
+	}
+}
+
+// Tee returns an EventHandler that passes each event it receives on to every
+// one of handlers, in order. Anchor names and versions are passed on to the
+// handlers implementing AnchorNameHandler and VersionHandler.
+func Tee(handlers ...EventHandler) EventHandler {
+	return &teeHandler{handlers}
+}
+
+type teeHandler struct {
+	handlers []EventHandler
+}
+
+func (t *teeHandler) AnchorName(mark Mark, anchor Anchor, name string) {
+	for _, h := range t.handlers {
+		passAnchorName(h, anchorNameEvent{mark, anchor, name})
+	}
+}
+
+func (t *teeHandler) DocumentVersion(mark Mark, version Version) {
+	for _, h := range t.handlers {
+		passVersion(h, mark, version)
+	}
+}
+
+func (t *teeHandler) DocumentStart(mark Mark) {
+	for _, h := range t.handlers {
+		h.DocumentStart(mark)
+	}
+}
+
+func (t *teeHandler) DocumentEnd() {
+	for _, h := range t.handlers {
+		h.DocumentEnd()
+	}
+}
+
+func (t *teeHandler) Null(mark Mark, anchor Anchor) {
+	for _, h := range t.handlers {
+		h.Null(mark, anchor)
+	}
+}
+
+func (t *teeHandler) Alias(mark Mark, anchor Anchor) {
+	for _, h := range t.handlers {
+		h.Alias(mark, anchor)
+	}
+}
+
+func (t *teeHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	for _, h := range t.handlers {
+		h.Scalar(mark, tag, anchor, value)
+	}
+}
+
+func (t *teeHandler) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	for _, h := range t.handlers {
+		h.SequenceStart(mark, tag, anchor)
+	}
+}
+
+func (t *teeHandler) SequenceEnd() {
+	for _, h := range t.handlers {
+		h.SequenceEnd()
+	}
+}
+
+func (t *teeHandler) MapStart(mark Mark, tag string, anchor Anchor) {
+	for _, h := range t.handlers {
+		h.MapStart(mark, tag, anchor)
+	}
+}
+
+func (t *teeHandler) MapEnd() {
+	for _, h := range t.handlers {
+		h.MapEnd()
+	}
+}
+
+// Filter returns an EventHandler that passes the events it receives on to
+// handler, leaving out the map entries and sequence items whose paths drop
+// reports true for, along with everything in them:
+//
+//	redact := yaml.Filter(emitter, func(path yaml.Path) bool {
+//		return len(path) > 0 && path[len(path)-1].Key == "password"
+//	})
+//
+// The paths drop is given are those Walk would give the input, with the
+// original indices of sequence items; so plain keys of YAML 1.1 documents
+// are spelled in YAML 1.2 terms, as yes is true. An entry is dropped along
+// with its key, as soon as the key starts; the root is never dropped.
+// Aliases to anchors in what was dropped are passed on as nulls.
+func Filter(handler EventHandler, drop func(path Path) bool) EventHandler {
+	return &filterHandler{handler: handler, drop: drop}
+}
+
+type filterHandler struct {
+	handler EventHandler
+	drop    func(path Path) bool
+	paths   pathTracker
+
+	skipDepth  int  // of collections open in a dropped node
+	skipValue  bool // the next node is the value of a dropped entry
+	dropped    map[Anchor]bool
+	anchorName *anchorNameEvent
+}
+
+// skip decides whether the node the next event starts is left out, and
+// passes on its anchor name if not. key is its value when it is a scalar.
+func (f *filterHandler) skip(anchor Anchor, key string, isScalar bool) (skip bool) {
+	switch frame := f.paths.top(); {
+	case f.skipDepth > 0:
+		skip = true
+	case frame == nil || frame.isKey:
+	case frame.isMap && frame.value:
+		skip, f.skipValue = f.skipValue, false
+	case frame.isMap:
+		skip = f.drop(frame.path.child(frame.entry(key, isScalar)))
+		f.skipValue = skip
+	default:
+		path, _ := f.paths.current()
+		skip = f.drop(path)
+	}
+
+	if skip && anchor != NullAnchor {
+		if f.dropped == nil {
+			f.dropped = make(map[Anchor]bool)
+		}
+		f.dropped[anchor] = true
+	} else if !skip && f.anchorName != nil {
+		passAnchorName(f.handler, *f.anchorName)
+	}
+	f.anchorName = nil
+	return skip
+}
+
+func (f *filterHandler) AnchorName(mark Mark, anchor Anchor, name string) {
+	f.anchorName = &anchorNameEvent{mark, anchor, name}
+}
+
+func (f *filterHandler) DocumentStart(mark Mark) {
+	f.paths.reset()
+	f.skipDepth, f.skipValue = 0, false
+	clear(f.dropped)
+	f.handler.DocumentStart(mark)
+}
+
+func (f *filterHandler) DocumentVersion(mark Mark, version Version) {
+	f.paths.version = version
+	passVersion(f.handler, mark, version)
+}
+
+func (f *filterHandler) DocumentEnd() {
+	f.handler.DocumentEnd()
+}
+
+func (f *filterHandler) Null(mark Mark, anchor Anchor) {
+	skip := f.skip(anchor, "", false)
+	f.paths.next("", false)
+	if !skip {
+		f.handler.Null(mark, anchor)
+	}
+}
+
+func (f *filterHandler) Alias(mark Mark, anchor Anchor) {
+	key, isScalar := f.paths.alias(anchor)
+	skip := f.skip(NullAnchor, key, isScalar)
+	f.paths.next(key, isScalar)
+	if skip {
+		return
+	} else if f.dropped[anchor] {
+		f.handler.Null(mark, NullAnchor)
+	} else {
+		f.handler.Alias(mark, anchor)
+	}
+}
+
+func (f *filterHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	key, isScalar := f.paths.scalar(anchor, tag, value)
+	skip := f.skip(anchor, key, isScalar)
+	f.paths.next(key, isScalar)
+	if !skip {
+		f.handler.Scalar(mark, tag, anchor, value)
+	}
+}
+
+func (f *filterHandler) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	if f.skip(anchor, "", false) {
+		f.skipDepth++
+	} else {
+		f.handler.SequenceStart(mark, tag, anchor)
+	}
+	f.paths.start(false)
+}
+
+func (f *filterHandler) SequenceEnd() {
+	f.paths.end()
+	if f.skipDepth > 0 {
+		f.skipDepth--
+	} else {
+		f.handler.SequenceEnd()
+	}
+}
+
+func (f *filterHandler) MapStart(mark Mark, tag string, anchor Anchor) {
+	if f.skip(anchor, "", false) {
+		f.skipDepth++
+	} else {
+		f.handler.MapStart(mark, tag, anchor)
+	}
+	f.paths.start(true)
+}
+
+func (f *filterHandler) MapEnd() {
+	f.paths.end()
+	if f.skipDepth > 0 {
+		f.skipDepth--
+	} else {
+		f.handler.MapEnd()
+	}
+}
+
+// Transform returns an EventHandler that passes the events it receives on to
+// handler, with the tag and value of each scalar replaced by what rewrite
+// returns for them:
+//
+//	redact := yaml.Transform(emitter, func(path yaml.Path, tag, value string) (string, string) {
+//		if len(path) > 0 && path[len(path)-1].Key == "password" {
+//			return "!", "REDACTED"
+//		}
+//		return tag, value
+//	})
+//
+// rewrite is given the paths of the input, and is called for the start of
+// each collection as well, with an empty value, to replace its tag; the
+// value it returns then is ignored. Empty nodes, which come as nulls, are
+// passed on as they are; plain scalars such as null and ~ are rewritten like
+// any other.
+func Transform(handler EventHandler, rewrite func(path Path, tag, value string) (string, string)) EventHandler {
+	return &transformHandler{handler: handler, rewrite: rewrite}
+}
+
+type transformHandler struct {
+	handler EventHandler
+	rewrite func(path Path, tag, value string) (string, string)
+	paths   pathTracker
+}
+
+func (t *transformHandler) AnchorName(mark Mark, anchor Anchor, name string) {
+	passAnchorName(t.handler, anchorNameEvent{mark, anchor, name})
+}
+
+func (t *transformHandler) DocumentStart(mark Mark) {
+	t.paths.reset()
+	t.handler.DocumentStart(mark)
+}
+
+func (t *transformHandler) DocumentVersion(mark Mark, version Version) {
+	t.paths.version = version
+	passVersion(t.handler, mark, version)
+}
+
+func (t *transformHandler) DocumentEnd() {
+	t.handler.DocumentEnd()
+}
+
+func (t *transformHandler) Null(mark Mark, anchor Anchor) {
+	t.paths.next("", false)
+	t.handler.Null(mark, anchor)
+}
+
+func (t *transformHandler) Alias(mark Mark, anchor Anchor) {
+	t.paths.next(t.paths.alias(anchor))
+	t.handler.Alias(mark, anchor)
+}
+
+func (t *transformHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	path, _ := t.paths.current()
+	t.paths.next(t.paths.scalar(anchor, tag, value))
+	tag, value = t.rewrite(path, tag, value)
+	t.handler.Scalar(mark, tag, anchor, value)
+}
+
+func (t *transformHandler) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	path, _ := t.paths.current()
+	t.paths.start(false)
+	tag, _ = t.rewrite(path, tag, "")
+	t.handler.SequenceStart(mark, tag, anchor)
+}
+
+func (t *transformHandler) SequenceEnd() {
+	t.paths.end()
+	t.handler.SequenceEnd()
+}
+
+func (t *transformHandler) MapStart(mark Mark, tag string, anchor Anchor) {
+	path, _ := t.paths.current()
+	t.paths.start(true)
+	tag, _ = t.rewrite(path, tag, "")
+	t.handler.MapStart(mark, tag, anchor)
+}
+
+func (t *transformHandler) MapEnd() {
+	t.paths.end()
+	t.handler.MapEnd()
+}
@@ -0,0 +1,176 @@
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// handleText passes the events of every document in text to handler.
+func handleText(t *testing.T, text string, handler EventHandler) {
+	t.Helper()
+	parser := NewParser(strings.NewReader(text))
+	for {
+		ok, err := parser.HandleNextDocument(handler)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		} else if !ok {
+			return
+		}
+	}
+}
+
+// eventText writes the events of text in the yaml-test-suite notation, after
+// passing them through the handler wrap returns.
+func eventText(t *testing.T, text string, wrap func(EventHandler) EventHandler) string {
+	t.Helper()
+	var out bytes.Buffer
+	writer := NewEventWriter(&out)
+	handleText(t, text, wrap(writer))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestTee(t *testing.T) {
+	const text = "a: &x [1, *x]\n--- !t b\n"
+	expected := eventText(t, text, func(h EventHandler) EventHandler { return h })
+
+	var outs [2]bytes.Buffer
+	writers := []*EventWriter{NewEventWriter(&outs[0]), NewEventWriter(&outs[1])}
+	handleText(t, text, Tee(writers[0], BaseHandler{}, writers[1]))
+	for i, writer := range writers {
+		writer.Close()
+		if outs[i].String() != expected {
+			t.Errorf("handler %d: expected\n%v\ngot\n%v", i, expected, outs[i].String())
+		}
+	}
+	if !strings.Contains(expected, "&x") {
+		t.Errorf("expected the anchor's name to be passed on, got\n%v", expected)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		text     string
+		drop     string // the String of the paths to drop
+		expected string
+	}{
+		{"user: u\npassword: p\n", "password",
+			"+STR\n+DOC\n+MAP\n=VAL :user\n=VAL :u\n-MAP\n-DOC\n-STR\n"},
+		{"[a, b, [c], d]", "[1] [2]",
+			"+STR\n+DOC\n+SEQ\n=VAL :a\n=VAL :d\n-SEQ\n-DOC\n-STR\n"},
+		// an entry whose key isn't a scalar is dropped by its position
+		{"a: 1\n? [k]\n: v\n~: n\n", "[#1] [#2]",
+			"+STR\n+DOC\n+MAP\n=VAL :a\n=VAL :1\n-MAP\n-DOC\n-STR\n"},
+		// aliases to what was dropped become nulls
+		{"secret: &s x\ncopy: *s\n", "secret",
+			"+STR\n+DOC\n+MAP\n=VAL :copy\n=VAL :\n-MAP\n-DOC\n-STR\n"},
+		{"a: {b: &k x, c: 1}\n*k : y\n", "a.c x",
+			"+STR\n+DOC\n+MAP\n=VAL :a\n+MAP\n=VAL :b\n=VAL &k :x\n-MAP\n-MAP\n-DOC\n-STR\n"},
+	}
+
+	for _, test := range tests {
+		drop := strings.Fields(test.drop)
+		out := eventText(t, test.text, func(h EventHandler) EventHandler {
+			return Filter(h, func(path Path) bool {
+				for _, d := range drop {
+					if path.String() == d {
+						return true
+					}
+				}
+				return false
+			})
+		})
+		if out != test.expected {
+			t.Errorf("%q dropping %v: expected\n%v\ngot\n%v", test.text, test.drop, test.expected, out)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	const text = "db: {user: u, password: p}\nlist: [password]\n"
+	out := eventText(t, text, func(h EventHandler) EventHandler {
+		return Transform(h, func(path Path, tag, value string) (string, string) {
+			if len(path) > 0 && path[len(path)-1].Key == "password" {
+				return "!", "REDACTED"
+			} else if value == "" {
+				return "!c", value
+			}
+			return tag, value
+		})
+	})
+
+	expected := "+STR\n+DOC\n+MAP <!c>\n=VAL :db\n+MAP <!c>\n=VAL :user\n=VAL :u\n=VAL :password\n=VAL \"REDACTED\n-MAP\n" +
+		"=VAL :list\n+SEQ <!c>\n=VAL :password\n-SEQ\n-MAP\n-DOC\n-STR\n"
+	if out != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, out)
+	}
+}
+
+type scalarCounter struct {
+	BaseHandler
+	n int
+}
+
+func (c *scalarCounter) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	c.n++
+}
+
+func TestBaseHandler(t *testing.T) {
+	var counter scalarCounter
+	handleText(t, "a: [b, ~, {c: d}]\n--- e\n", &counter)
+	if counter.n != 6 {
+		t.Errorf("expected 6 scalars, got %d", counter.n)
+	}
+}
+
+type scalarPaths struct {
+	BasePathHandler
+	paths map[string]Path
+}
+
+func (s *scalarPaths) Scalar(path Path, mark Mark, tag string, anchor Anchor, value string) {
+	s.paths[value] = path
+}
+
+// TrackPaths gives the values of a document the paths Walk does.
+func TestTrackPathsWalk(t *testing.T) {
+	const text = "a: v1\n? [k]\n: v2\n~: v3\nb: [v4, {c: v5}]\n&x d: v6\n*x : v7\n"
+
+	tracked := scalarPaths{paths: make(map[string]Path)}
+	handleText(t, text, TrackPaths(&tracked))
+
+	walked := 0
+	for path, node := range mustLoad(t, text).Walk() {
+		if !strings.HasPrefix(node.Value, "v") {
+			continue
+		}
+		walked++
+		if !reflect.DeepEqual(tracked.paths[node.Value], path) {
+			t.Errorf("%v: Walk gives %#v, TrackPaths %#v", node.Value, path, tracked.paths[node.Value])
+		}
+	}
+	if walked != 7 {
+		t.Errorf("expected Walk to reach 7 values, got %d", walked)
+	}
+}
+
+// Plain keys of a YAML 1.1 document have the spelling Walk gives them.
+func TestTrackPathsVersion(t *testing.T) {
+	const text = "%YAML 1.1\n---\nyes: v1\n010: v2\n~: v3\n&k off: v4\n*k : v5\n"
+
+	tracked := scalarPaths{paths: make(map[string]Path)}
+	handleText(t, text, TrackPaths(&tracked))
+
+	for path, node := range mustLoad(t, text).Walk() {
+		if strings.HasPrefix(node.Value, "v") && !reflect.DeepEqual(tracked.paths[node.Value], path) {
+			t.Errorf("%v: Walk gives %#v, TrackPaths %#v", node.Value, path, tracked.paths[node.Value])
+		}
+	}
+	if key := tracked.paths["v1"][0].Key; key != "true" {
+		t.Errorf("expected the key yes to be true, got %q", key)
+	}
+}
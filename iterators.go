@@ -0,0 +1,124 @@
+package yaml
+
+import (
+	"io"
+	"iter"
+)
+
+// Documents iterates over the documents of the stream in reader:
+//
+//	for doc, err := range yaml.Documents(reader) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Iteration stops after the first error.
+func Documents(reader io.Reader) iter.Seq2[*Node, error] {
+	return func(yield func(*Node, error) bool) {
+		decoder := NewDecoder(reader)
+		for {
+			node, err := decoder.Decode()
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(node, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Entries iterates over the key/value pairs of a map, in document order.
+// It yields nothing for other nodes.
+func (n *Node) Entries() iter.Seq2[*Node, *Node] {
+	return func(yield func(*Node, *Node) bool) {
+		if n.Type != NODE_MAP {
+			return
+		}
+		for _, pair := range n.Pairs {
+			if !yield(pair.Key, pair.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Items iterates over the items of a sequence along with their indices.
+// It yields nothing for other nodes.
+func (n *Node) Items() iter.Seq2[int, *Node] {
+	return func(yield func(int, *Node) bool) {
+		if n.Type != NODE_SEQUENCE {
+			return
+		}
+		for i, item := range n.Children {
+			if !yield(i, item) {
+				return
+			}
+		}
+	}
+}
+
+// Walk iterates over n and all the nodes below it, depth first, yielding each
+// node with its path relative to n. Map keys aren't visited themselves; an
+// entry whose key isn't a scalar is reached by its position in the map.
+//
+// A node reached again through an alias is yielded once more at its new path,
+// along with everything below it, but Walk does not descend into a node it is
+// already inside of, so it ends on recursive trees too. Since a few aliases
+// to aliases can make a small document expand to a huge tree, use WalkOnce
+// on untrusted input.
+func (n *Node) Walk() iter.Seq2[Path, *Node] {
+	return func(yield func(Path, *Node) bool) {
+		walkNode(Path{}, n, make(map[*Node]bool), false, yield)
+	}
+}
+
+// WalkOnce is Walk, yielding each node only at the first path it is reached
+// by and skipping it wherever an alias leads to it again, so it takes time in
+// proportion to the size of the document.
+func (n *Node) WalkOnce() iter.Seq2[Path, *Node] {
+	return func(yield func(Path, *Node) bool) {
+		walkNode(Path{}, n, make(map[*Node]bool), true, yield)
+	}
+}
+
+// walkNode walks the tree below n. seen holds the nodes being walked, or
+// with once set every node walked so far.
+func walkNode(path Path, n *Node, seen map[*Node]bool, once bool, yield func(Path, *Node) bool) bool {
+	if once && seen[n] {
+		return true
+	}
+	if !yield(path, n) {
+		return false
+	}
+	if seen[n] {
+		return true
+	}
+
+	seen[n] = true
+	if !once {
+		defer delete(seen, n)
+	}
+
+	switch n.Type {
+	case NODE_SEQUENCE:
+		for i, item := range n.Children {
+			if !walkNode(path.child(PathElement{Index: i, IsIndex: true}), item, seen, once, yield) {
+				return false
+			}
+		}
+	case NODE_MAP:
+		for i, pair := range n.Pairs {
+			if !walkNode(path.child(pairPathElement(i, pair)), pair.Value, seen, once, yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
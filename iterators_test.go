@@ -0,0 +1,47 @@
+package yaml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func walkedPaths(walk func(yield func(Path, *Node) bool)) []string {
+	var paths []string
+	for path, node := range walk {
+		paths = append(paths, path.String()+"="+node.Value)
+	}
+	return paths
+}
+
+func TestWalk(t *testing.T) {
+	doc := mustLoad(t, "a: &x {b: 1}\nc: *x\n? [k]\n: 2\nd: [3]\n")
+
+	expected := "=|a=|a.b=1|c=|c.b=1|[#2]=2|d=|d[0]=3"
+	if got := strings.Join(walkedPaths(doc.Walk()), "|"); got != expected {
+		t.Errorf("Walk: expected %v, got %v", expected, got)
+	}
+
+	expected = "=|a=|a.b=1|[#2]=2|d=|d[0]=3"
+	if got := strings.Join(walkedPaths(doc.WalkOnce()), "|"); got != expected {
+		t.Errorf("WalkOnce: expected %v, got %v", expected, got)
+	}
+}
+
+// WalkOnce takes time in proportion to the document even where aliases
+// expand it exponentially.
+func TestWalkOnceAliases(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("a0: &a0 [x, x]\n")
+	for i := 1; i < 40; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+	}
+
+	n := 0
+	for range mustLoad(t, b.String()).WalkOnce() {
+		n++
+	}
+	if n != 1+40+2 {
+		t.Errorf("expected %d nodes, got %d", 1+40+2, n)
+	}
+}
@@ -0,0 +1,314 @@
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the output ToJSON may write for aliases
+const (
+	json_MAX_EXPANSION       = 64      // bytes per byte of input
+	json_EXPANSION_ALLOWANCE = 1 << 20 // bytes
+)
+
+// ToJSON converts each document of the YAML stream in reader to a JSON
+// value, written to writer on a line of its own. The parser's events are
+// written out as they come, without building the documents in memory.
+//
+// Scalars are typed by the core schema (see ResolveTag) and aliases are
+// expanded. Map keys must be strings, and .inf and .nan, which JSON has no
+// notation for, are rejected. Since aliases to aliases can make a small
+// input expand exponentially, ToJSON fails once the aliases have written
+// more than json_MAX_EXPANSION bytes for each byte of input read, on top of
+// json_EXPANSION_ALLOWANCE.
+func ToJSON(reader io.Reader, writer io.Writer) error {
+	buffered := bufio.NewWriter(writer)
+	handler := newJSONWriter(buffered)
+
+	parser := NewParser(reader)
+	for {
+		ok, err := parser.HandleNextDocument(handler)
+		if err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+	}
+	return buffered.Flush()
+}
+
+// FromJSON converts each JSON value in reader to a YAML document in block
+// style, written to writer by an Emitter. Object keys keep their order.
+func FromJSON(reader io.Reader, writer io.Writer) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	emitter := NewEmitter(writer)
+	for {
+		node, err := jsonNode(decoder)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := emitter.Emit(node); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonNode reads the next JSON value as a Node.
+func jsonNode(decoder *json.Decoder) (*Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			node := &Node{Type: NODE_SEQUENCE, Mark: NullMark, Tag: "?"}
+			for decoder.More() {
+				item, err := jsonNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.Children = append(node.Children, item)
+			}
+			_, err = decoder.Token()
+			return node, err
+		}
+
+		node := &Node{Type: NODE_MAP, Mark: NullMark, Tag: "?"}
+		for decoder.More() {
+			key, err := jsonNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			value, err := jsonNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Pairs = append(node.Pairs, NodePair{key, value})
+		}
+		_, err = decoder.Token()
+		return node, err
+	case string:
+		return &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "!", Value: token}, nil
+	case json.Number:
+		return &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "?", Value: token.String()}, nil
+	case bool:
+		return &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "?", Value: strconv.FormatBool(token)}, nil
+	}
+	return &Node{Type: NODE_NULL, Mark: NullMark}, nil
+}
+
+// jsonWriter is an EventHandler that writes each document as a JSON value.
+type jsonWriter struct {
+	writer io.Writer
+	err    error
+
+	// the open collections, with the number of keys, values and items
+	// written to each
+	stack []jsonCollection
+
+	// the JSON text of the anchored nodes, and of those still being written
+	anchors  map[Anchor]jsonValue
+	captures []jsonCapture
+
+	// the bytes written for aliases so far, bounded by the input's size
+	expanded int
+
+	// the YAML version of the document, whose plain scalars are typed by
+	// their YAML 1.2 meaning
+	version Version
+}
+
+type jsonCollection struct {
+	isMap bool
+	count int
+}
+
+type jsonValue struct {
+	text     string
+	isString bool
+}
+
+type jsonCapture struct {
+	anchor Anchor
+	depth  int
+	text   bytes.Buffer
+}
+
+func newJSONWriter(writer io.Writer) *jsonWriter {
+	return &jsonWriter{writer: writer}
+}
+
+func (j *jsonWriter) write(text string) {
+	for i := range j.captures {
+		j.captures[i].text.WriteString(text)
+	}
+	if j.err == nil {
+		_, j.err = io.WriteString(j.writer, text)
+	}
+	if j.err != nil {
+		panic(j.err)
+	}
+}
+
+// value writes the separator due before the next value of the current
+// collection, checking that a map key is a string.
+func (j *jsonWriter) value(mark Mark, isString bool) {
+	if len(j.stack) == 0 {
+		return
+	}
+
+	top := &j.stack[len(j.stack)-1]
+	if top.isMap && top.count%2 == 0 && !isString {
+		panic(&ParseError{mark, ERR_JSON_KEY})
+	}
+
+	switch {
+	case top.count == 0:
+	case top.isMap && top.count%2 == 1:
+		j.write(":")
+	default:
+		j.write(",")
+	}
+	top.count++
+}
+
+func (j *jsonWriter) DocumentStart(mark Mark) {
+	j.anchors = make(map[Anchor]jsonValue)
+	j.version = Version{}
+}
+
+func (j *jsonWriter) DocumentVersion(mark Mark, version Version) {
+	j.version = version
+}
+
+func (j *jsonWriter) DocumentEnd() {
+	j.write("\n")
+}
+
+func (j *jsonWriter) Null(mark Mark, anchor Anchor) {
+	j.scalar(mark, anchor, jsonValue{"null", false})
+}
+
+func (j *jsonWriter) Alias(mark Mark, anchor Anchor) {
+	value, ok := j.anchors[anchor]
+	if !ok {
+		panic(&ParseError{mark, ERR_RECURSIVE_ALIAS})
+	}
+	j.expanded += len(value.text)
+	if j.expanded > json_EXPANSION_ALLOWANCE+json_MAX_EXPANSION*mark.Pos {
+		panic(&ParseError{mark, ERR_JSON_EXPANSION})
+	}
+	j.value(mark, value.isString)
+	j.write(value.text)
+}
+
+func (j *jsonWriter) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	tag, value = coreScalar(tag, value, j.version)
+	j.scalar(mark, anchor, jsonScalar(mark, tag, value))
+}
+
+func (j *jsonWriter) scalar(mark Mark, anchor Anchor, value jsonValue) {
+	if anchor != NullAnchor {
+		j.anchors[anchor] = value
+	}
+	j.value(mark, value.isString)
+	j.write(value.text)
+}
+
+func (j *jsonWriter) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	j.start(mark, anchor, false, "[")
+}
+
+func (j *jsonWriter) SequenceEnd() {
+	j.end("]")
+}
+
+func (j *jsonWriter) MapStart(mark Mark, tag string, anchor Anchor) {
+	j.start(mark, anchor, true, "{")
+}
+
+func (j *jsonWriter) MapEnd() {
+	j.end("}")
+}
+
+func (j *jsonWriter) start(mark Mark, anchor Anchor, isMap bool, delim string) {
+	j.value(mark, false)
+	if anchor != NullAnchor {
+		j.captures = append(j.captures, jsonCapture{anchor: anchor, depth: len(j.stack)})
+	}
+	j.write(delim)
+	j.stack = append(j.stack, jsonCollection{isMap: isMap})
+}
+
+func (j *jsonWriter) end(delim string) {
+	j.write(delim)
+	j.stack = j.stack[:len(j.stack)-1]
+
+	if l := len(j.captures); l > 0 && j.captures[l-1].depth == len(j.stack) {
+		capture := &j.captures[l-1]
+		j.anchors[capture.anchor] = jsonValue{capture.text.String(), false}
+		j.captures = j.captures[:l-1]
+	}
+}
+
+var jsonNumberExp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// jsonScalar converts a scalar to JSON text by its resolved tag.
+func jsonScalar(mark Mark, tag string, value string) jsonValue {
+	switch ResolveTag(tag, value) {
+	case TAG_NULL:
+		if coreNullExp.MatchString(value) {
+			return jsonValue{"null", false}
+		}
+	case TAG_BOOL:
+		if coreBoolExp.MatchString(value) {
+			return jsonValue{strings.ToLower(value), false}
+		}
+	case TAG_INT:
+		if jsonNumberExp.MatchString(value) {
+			return jsonValue{value, false}
+		}
+
+		digits, base := strings.TrimPrefix(value, "+"), 10
+		if strings.HasPrefix(digits, "0o") {
+			digits, base = digits[2:], 8
+		} else if strings.HasPrefix(digits, "0x") {
+			digits, base = digits[2:], 16
+		}
+		if i, ok := new(big.Int).SetString(digits, base); ok {
+			return jsonValue{i.String(), false}
+		}
+	case TAG_FLOAT:
+		if jsonNumberExp.MatchString(value) {
+			return jsonValue{value, false}
+		}
+
+		switch strings.ToLower(strings.TrimLeft(value, "+-")) {
+		case ".inf", ".nan":
+			panic(&ParseError{mark, ERR_JSON_NONFINITE + value})
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return jsonValue{strconv.FormatFloat(f, 'g', -1, 64), false}
+		}
+	default:
+		var text bytes.Buffer
+		encoder := json.NewEncoder(&text)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(value)
+		return jsonValue{strings.TrimSuffix(text.String(), "\n"), true}
+	}
+	panic(&ParseError{mark, ERR_INVALID_SCALAR})
+}
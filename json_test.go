@@ -0,0 +1,39 @@
+package yaml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToJSONAliases(t *testing.T) {
+	var out strings.Builder
+	if err := ToJSON(strings.NewReader("a: &x {b: [1, 'c']}\nd: *x\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"a":{"b":[1,"c"]},"d":{"b":[1,"c"]}}` + "\n"; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+// Aliases that expand exponentially fail instead of writing gigabytes.
+func TestToJSONAliasExpansion(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("a0: &a0 [lol, lol, lol, lol, lol, lol, lol, lol, lol]\n")
+	for i := 1; i < 10; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [", i, i)
+		for j := 0; j < 9; j++ {
+			fmt.Fprintf(&b, "*a%d, ", i-1)
+		}
+		b.WriteString("x]\n")
+	}
+
+	var out strings.Builder
+	err := ToJSON(strings.NewReader(b.String()), &out)
+	if err == nil || !strings.Contains(err.Error(), ERR_JSON_EXPANSION) {
+		t.Errorf("expected %q, got %v", ERR_JSON_EXPANSION, err)
+	}
+	if out.Len() > 2*json_EXPANSION_ALLOWANCE {
+		t.Errorf("expected the output to stop early, got %d bytes", out.Len())
+	}
+}
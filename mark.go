@@ -1,5 +1,7 @@
 package yaml
 
+import "fmt"
+
 type Mark struct {
 	Pos    int
 	Line   int
@@ -9,5 +11,5 @@ type Mark struct {
 var NullMark Mark = Mark{Pos: -1, Line: -1, Column: -1}
 
 func (m Mark) String() string {
-	return "Pos:%v Line:%v Col:%v"
+	return fmt.Sprintf("Pos:%v Line:%v Col:%v", m.Pos, m.Line, m.Column)
 }
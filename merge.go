@@ -0,0 +1,142 @@
+package yaml
+
+// SequenceStrategy selects how Merge combines two sequences.
+type SequenceStrategy int
+
+const (
+	// the overlay's sequence replaces the base's
+	MERGE_SEQ_REPLACE SequenceStrategy = iota
+	// the overlay's items are appended to the base's
+	MERGE_SEQ_APPEND
+	// items that are maps with the same value under MergeOptions.MergeKey
+	// are merged, and the overlay's other items appended
+	MERGE_SEQ_BY_KEY
+)
+
+type MergeOptions struct {
+	Sequences SequenceStrategy
+
+	// MergeKey is the key that identifies the items of sequences merged
+	// with MERGE_SEQ_BY_KEY; "name" if empty.
+	MergeKey string
+
+	// NullDeletes makes an overlay entry with a null value remove the key
+	// instead of setting it to null.
+	NullDeletes bool
+}
+
+// Merge layers the overlays over base, in order, and returns the result:
+// maps merge recursively, key by key, sequences combine according to
+// opts.Sequences, and anything else in an overlay replaces what is below
+// it. Keys match by their resolved values.
+//
+// The result is made of copies of the input nodes, which are left alone,
+// so every node in it keeps the Mark and Source of the document it came
+// from; Node.Origin tells where a value was set. A node that base shares
+// between places through an alias is copied before anything is merged into
+// it, so an overlay changes the places it names only.
+func Merge(opts MergeOptions, base *Node, overlays ...*Node) *Node {
+	if len(opts.MergeKey) == 0 {
+		opts.MergeKey = "name"
+	}
+
+	result := base.Clone()
+	m := &merger{opts: opts, owner: newNodeOwner(result)}
+	for _, overlay := range overlays {
+		result = m.merge(result, overlay)
+	}
+	return result
+}
+
+type merger struct {
+	opts  MergeOptions
+	owner *nodeOwner
+}
+
+// clone copies a node of an overlay into the result.
+func (m *merger) clone(n *Node) *Node {
+	return m.owner.add(n.Clone())
+}
+
+func (m *merger) merge(dst *Node, src *Node) *Node {
+	switch {
+	case dst.Type == NODE_MAP && src.Type == NODE_MAP && dst.ResolvedTag() == src.ResolvedTag():
+		dst = m.owner.own(dst)
+		m.mergeMaps(dst, src)
+		return dst
+	case dst.Type == NODE_SEQUENCE && src.Type == NODE_SEQUENCE && dst.ResolvedTag() == src.ResolvedTag():
+		switch m.opts.Sequences {
+		case MERGE_SEQ_APPEND:
+			dst = m.owner.own(dst)
+			for _, item := range src.Children {
+				dst.Children = append(dst.Children, m.clone(item))
+			}
+			return dst
+		case MERGE_SEQ_BY_KEY:
+			dst = m.owner.own(dst)
+			m.mergeByKey(dst, src)
+			return dst
+		}
+	}
+	return m.clone(src)
+}
+
+func (m *merger) mergeMaps(dst *Node, src *Node) {
+	for _, entry := range src.Pairs {
+		key := compareKey(entry.Key, make(map[*Node]bool))
+
+		i := 0
+		for ; i < len(dst.Pairs); i++ {
+			if compareKey(dst.Pairs[i].Key, make(map[*Node]bool)) == key {
+				break
+			}
+		}
+
+		switch {
+		case m.opts.NullDeletes && entry.Value.ResolvedTag() == TAG_NULL:
+			if i < len(dst.Pairs) {
+				dst.Pairs = append(dst.Pairs[:i], dst.Pairs[i+1:]...)
+			}
+		case i < len(dst.Pairs):
+			dst.Pairs[i].Value = m.merge(dst.Pairs[i].Value, entry.Value)
+		default:
+			dst.Pairs = append(dst.Pairs, NodePair{m.clone(entry.Key), m.clone(entry.Value)})
+		}
+	}
+}
+
+func (m *merger) mergeByKey(dst *Node, src *Node) {
+	for _, item := range src.Children {
+		key := m.itemKey(item)
+		if len(key) == 0 {
+			dst.Children = append(dst.Children, m.clone(item))
+			continue
+		}
+
+		i := 0
+		for ; i < len(dst.Children); i++ {
+			if m.itemKey(dst.Children[i]) == key {
+				break
+			}
+		}
+
+		if i < len(dst.Children) {
+			dst.Children[i] = m.merge(dst.Children[i], item)
+		} else {
+			dst.Children = append(dst.Children, m.clone(item))
+		}
+	}
+}
+
+// itemKey returns what identifies a sequence item merged by key, or "" if
+// it has no merge key.
+func (m *merger) itemKey(item *Node) string {
+	if item.Type != NODE_MAP {
+		return ""
+	}
+	value := item.Get(m.opts.MergeKey)
+	if value == nil || value.Type == NODE_SEQUENCE || value.Type == NODE_MAP {
+		return ""
+	}
+	return compareKey(value, make(map[*Node]bool))
+}
@@ -0,0 +1,53 @@
+package yaml
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := mustLoad(t, "a: 1\nmap: {x: 1, y: 2}\nlist: [1, 2]\nitems: [{name: a, v: 1}, {name: b, v: 2}]\n")
+	overlay := mustLoad(t, "a: 2\nmap: {y: 3, z: ~}\nlist: [3]\nitems: [{name: b, v: 3}, {name: c, v: 4}]\n")
+
+	tests := []struct {
+		opts     MergeOptions
+		expected string
+	}{
+		{MergeOptions{}, "a: 2\nmap: {x: 1, y: 3, z: ~}\nlist: [3]\nitems: [{name: b, v: 3}, {name: c, v: 4}]\n"},
+		{MergeOptions{Sequences: MERGE_SEQ_APPEND}, "a: 2\nmap: {x: 1, y: 3, z: ~}\nlist: [1, 2, 3]\nitems: [{name: a, v: 1}, {name: b, v: 2}, {name: b, v: 3}, {name: c, v: 4}]\n"},
+		{MergeOptions{Sequences: MERGE_SEQ_BY_KEY, NullDeletes: true}, "a: 2\nmap: {x: 1, y: 3}\nlist: [1, 2, 3]\nitems: [{name: a, v: 1}, {name: b, v: 3}, {name: c, v: 4}]\n"},
+	}
+
+	for _, test := range tests {
+		checkValues(t, "merged", Merge(test.opts, base, overlay), test.expected)
+	}
+	checkValues(t, "base", base, "a: 1\nmap: {x: 1, y: 2}\nlist: [1, 2]\nitems: [{name: a, v: 1}, {name: b, v: 2}]\n")
+}
+
+// Nodes shared through aliases are copied before anything is merged into
+// them, so an overlay changes the places it names only.
+func TestMergeAliases(t *testing.T) {
+	const src = "base: &b {x: 1, list: [1], deep: {y: 2}}\nprod: *b\n"
+
+	tests := []struct {
+		opts              MergeOptions
+		overlay, expected string
+	}{
+		{MergeOptions{}, "prod: {x: 4}",
+			"base: {x: 1, list: [1], deep: {y: 2}}\nprod: {x: 4, list: [1], deep: {y: 2}}\n"},
+		{MergeOptions{}, "base: {deep: {y: 5}}",
+			"base: {x: 1, list: [1], deep: {y: 5}}\nprod: {x: 1, list: [1], deep: {y: 2}}\n"},
+		{MergeOptions{Sequences: MERGE_SEQ_APPEND}, "prod: {list: [2]}",
+			"base: {x: 1, list: [1], deep: {y: 2}}\nprod: {x: 1, list: [1, 2], deep: {y: 2}}\n"},
+	}
+
+	for _, test := range tests {
+		doc := mustLoad(t, src)
+		checkValues(t, test.overlay, Merge(test.opts, doc, mustLoad(t, test.overlay)), test.expected)
+		checkValues(t, "base", doc, src)
+	}
+
+	// an alias within an overlay stays separate from the place it copies
+	doc := mustLoad(t, "a: {}\n")
+	merged := Merge(MergeOptions{}, doc, mustLoad(t, "a: &o {k: 1}\nb: *o\n"), mustLoad(t, "b: {k: 2}"))
+	checkValues(t, "two overlays", merged, "a: {k: 1}\nb: {k: 2}\n")
+}
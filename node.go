@@ -0,0 +1,181 @@
+package yaml
+
+import "fmt"
+
+type NodeType int
+
+const (
+	NODE_UNDEFINED NodeType = iota
+	NODE_NULL
+	NODE_SCALAR
+	NODE_SEQUENCE
+	NODE_MAP
+)
+
+var nodeTypeNames []string = []string{
+	"UNDEFINED",
+	"NULL",
+	"SCALAR",
+	"SEQUENCE",
+	"MAP",
+}
+
+func (t NodeType) String() string {
+	if t < 0 || int(t) >= len(nodeTypeNames) {
+		return fmt.Sprintf("NodeType(%d)", int(t))
+	}
+	return nodeTypeNames[t]
+}
+
+// Node is a node of a document tree, as built by Load. An alias is resolved
+// to the very node its anchor is attached to, so nodes may be shared.
+type Node struct {
+	Type NodeType
+	Mark Mark
+	Tag  string
+
+	// Source names where the node was read from, such as a file name, if
+	// the decoder was told (see Decoder.Source).
+	Source string
+
+	// Anchor is the name of the anchor defined on this node, if any.
+	Anchor string
+
+	// Value holds the value of a scalar.
+	Value string
+
+	// Children holds the items of a sequence and Pairs the entries of a
+	// map, in document order.
+	Children []*Node
+	Pairs    []NodePair
+}
+
+type NodePair struct {
+	Key   *Node
+	Value *Node
+}
+
+// Origin describes where the node was read from, as source:line:column
+// with 1-based lines and columns, or "" if that isn't known.
+func (n *Node) Origin() string {
+	if n.Mark == NullMark {
+		return n.Source
+	}
+
+	source := n.Source
+	if len(source) == 0 {
+		source = "<input>"
+	}
+	return fmt.Sprintf("%v:%d:%d", source, n.Mark.Line+1, n.Mark.Column+1)
+}
+
+func (n *Node) IsNull() bool {
+	return n.Type == NODE_NULL
+}
+
+func (n *Node) IsScalar() bool {
+	return n.Type == NODE_SCALAR
+}
+
+func (n *Node) IsSequence() bool {
+	return n.Type == NODE_SEQUENCE
+}
+
+func (n *Node) IsMap() bool {
+	return n.Type == NODE_MAP
+}
+
+// Len returns the number of items in a sequence or entries in a map.
+func (n *Node) Len() int {
+	switch n.Type {
+	case NODE_SEQUENCE:
+		return len(n.Children)
+	case NODE_MAP:
+		return len(n.Pairs)
+	}
+	return 0
+}
+
+// Get returns the value of the first map entry whose key is a scalar with
+// the given value, or nil.
+func (n *Node) Get(key string) *Node {
+	for _, pair := range n.Pairs {
+		if pair.Key.Type == NODE_SCALAR && pair.Key.Value == key {
+			return pair.Value
+		}
+	}
+	return nil
+}
+
+// Index returns the i-th item of a sequence, or nil.
+func (n *Node) Index(i int) *Node {
+	if n.Type != NODE_SEQUENCE || i < 0 || i >= len(n.Children) {
+		return nil
+	}
+	return n.Children[i]
+}
+
+// Clone returns a deep copy of n. Nodes shared within n, as through
+// aliases, stay shared in the copy.
+func (n *Node) Clone() *Node {
+	return cloneNode(n, make(map[*Node]*Node))
+}
+
+func cloneNode(n *Node, clones map[*Node]*Node) *Node {
+	if clone, ok := clones[n]; ok {
+		return clone
+	}
+
+	clone := &Node{Type: n.Type, Mark: n.Mark, Tag: n.Tag, Source: n.Source, Anchor: n.Anchor, Value: n.Value}
+	clones[n] = clone
+	for _, item := range n.Children {
+		clone.Children = append(clone.Children, cloneNode(item, clones))
+	}
+	for _, pair := range n.Pairs {
+		clone.Pairs = append(clone.Pairs, NodePair{cloneNode(pair.Key, clones), cloneNode(pair.Value, clones)})
+	}
+	return clone
+}
+
+// nodeOwner copies the nodes of a document that are shared, as through
+// aliases, before they are changed, so that each change shows in one place
+// only.
+type nodeOwner struct {
+	refs map[*Node]int // the number of references to each node
+}
+
+func newNodeOwner(root *Node) *nodeOwner {
+	o := &nodeOwner{refs: make(map[*Node]int)}
+	countRefs(root, o.refs)
+	return o
+}
+
+// own returns the node to change in place of the reference to node it was
+// reached by: node itself, or a copy of node if it's referenced elsewhere
+// too. The copy has no anchor, and shares node's children until they are
+// owned in turn.
+func (o *nodeOwner) own(node *Node) *Node {
+	if o.refs[node] <= 1 {
+		return node
+	}
+
+	o.refs[node]--
+	owned := &Node{Type: node.Type, Mark: node.Mark, Tag: node.Tag, Source: node.Source, Value: node.Value}
+	owned.Children = append(owned.Children, node.Children...)
+	owned.Pairs = append(owned.Pairs, node.Pairs...)
+	for _, item := range owned.Children {
+		o.refs[item]++
+	}
+	for _, pair := range owned.Pairs {
+		o.refs[pair.Key]++
+		o.refs[pair.Value]++
+	}
+	o.refs[owned] = 1
+	return owned
+}
+
+// add counts the references within node, which is new to the document.
+func (o *nodeOwner) add(node *Node) *Node {
+	countRefs(node, o.refs)
+	return node
+}
@@ -1,75 +1,190 @@
 package yaml
 
+// nodeBuilder is an EventHandler that builds a Node tree out of a document's
+// events.
 type nodeBuilder struct {
-	root interface{}
+	root *Node
 
-	stack   []interface{}
-	anchors []interface{}
+	// allow aliases to an ancestor, which makes the tree cyclic
+	allowRecursion bool
+
+	// the Source of the nodes built
+	source string
+
+	// if set, the mark of the last scalar, alias or collection start within
+	// each collection, which is where its source text ends
+	lastMarks map[*Node]Mark
+
+	stack   []*Node
+	anchors []*Node
+	names   map[Anchor]string
+
+	// the YAML version of the document, whose plain scalars are rewritten
+	// to their YAML 1.2 meaning
+	version Version
 
 	// Pushed keys
 	keys []struct {
-		node interface{}
+		node *Node
 		flag bool
 	}
 	mapDepth uint
 }
 
-/*
 func newNodeBuilder() *nodeBuilder {
 	return &nodeBuilder{
-		stack: make([]interface{}, 0),
-		anchors: make([]interface{}, 1),
+		stack:   make([]*Node, 0),
+		anchors: make([]*Node, 1), // since the anchors start at 1
+		names:   make(map[Anchor]string),
 	}
 }
 
-func (n *nodeBuilder) OnDocumentStart(mark Mark) {
-
+// Root returns the document node once the document has been handled.
+func (n *nodeBuilder) Root() *Node {
+	if n.root == nil {
+		return &Node{Type: NODE_NULL, Mark: NullMark}
+	}
+	return n.root
 }
 
-func (n *nodeBuilder) OnDocumentEnd() {
+func (n *nodeBuilder) DocumentStart(mark Mark) {
 
 }
 
-func (n *nodeBuilder) OnNull(mark Mark, anchor Anchor) {
-	n.pushAnchor(anchor)
-}
+func (n *nodeBuilder) DocumentEnd() {
 
-func (n *nodeBuilder) OnAlias(mark Mark, anchor Anchor) {
+}
 
+func (n *nodeBuilder) DocumentVersion(mark Mark, version Version) {
+	n.version = version
 }
 
-func (n *nodeBuilder) OnScalar(mark Mark, tag string, anchor Anchor, value string) {
+func (n *nodeBuilder) AnchorName(mark Mark, anchor Anchor, name string) {
+	n.names[anchor] = name
+}
 
+func (n *nodeBuilder) Null(mark Mark, anchor Anchor) {
+	n.pushAnchor(mark, anchor).Type = NODE_NULL
+	n.pop()
 }
 
-func (n *nodeBuilder) OnSequenceStart(mark Mark, tag string, anchor Anchor) {
+func (n *nodeBuilder) Alias(mark Mark, anchor Anchor) {
+	n.markLast(mark)
+	if int(anchor) >= len(n.anchors) || n.anchors[anchor] == nil {
+		panic(&ParseError{mark, ERR_UNKNOWN_ANCHOR + n.names[anchor]})
+	}
+
+	node := n.anchors[anchor]
+	if !n.allowRecursion {
+		for _, ancestor := range n.stack {
+			if ancestor == node {
+				panic(&ParseError{mark, ERR_RECURSIVE_ALIAS + n.names[anchor]})
+			}
+		}
+	}
 
+	n.push(node)
+	n.pop()
 }
 
-func (n *nodeBuilder) OnSequenceEnd() {
+func (n *nodeBuilder) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	n.markLast(mark)
+	node := n.pushAnchor(mark, anchor)
+	node.Type = NODE_SCALAR
+	node.Tag, node.Value = coreScalar(tag, value, n.version)
+	if ResolveTag(node.Tag, node.Value) == TAG_NULL {
+		// a plain null, spelled any way, is a null node like an empty one
+		node.Type, node.Tag, node.Value = NODE_NULL, "", ""
+	}
+	n.pop()
+}
 
+func (n *nodeBuilder) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	n.markLast(mark)
+	node := n.pushAnchor(mark, anchor)
+	node.Type = NODE_SEQUENCE
+	node.Tag = tag
 }
 
-func (n *nodeBuilder) OnMapStart(mark Mark, tag string, anchor Anchor) {
+func (n *nodeBuilder) SequenceEnd() {
+	n.pop()
+}
 
+func (n *nodeBuilder) MapStart(mark Mark, tag string, anchor Anchor) {
+	n.markLast(mark)
+	node := n.pushAnchor(mark, anchor)
+	node.Type = NODE_MAP
+	node.Tag = tag
+	n.mapDepth++
 }
 
-func (n *nodeBuilder) OnMapEnd() {
+func (n *nodeBuilder) MapEnd() {
+	n.mapDepth--
+	n.pop()
+}
 
+func (n *nodeBuilder) markLast(mark Mark) {
+	if n.lastMarks != nil {
+		for _, node := range n.stack {
+			n.lastMarks[node] = mark
+		}
+	}
 }
 
-func (n *nodeBuilder) pushAnchor(anchor Anchor) interface{} {
-	return nil
+func (n *nodeBuilder) pushAnchor(mark Mark, anchor Anchor) *Node {
+	node := &Node{Mark: mark, Source: n.source}
+	n.registerAnchor(anchor, node)
+	n.push(node)
+	return node
 }
-/*
-func (n *nodeBuilder) push(detail::node& node) {
+
+func (n *nodeBuilder) push(node *Node) {
+	l := len(n.stack)
+	needsKey := l > 0 && n.stack[l-1].Type == NODE_MAP && uint(len(n.keys)) < n.mapDepth
+
+	n.stack = append(n.stack, node)
+	if needsKey {
+		n.keys = append(n.keys, struct {
+			node *Node
+			flag bool
+		}{node, false})
+	}
 }
 
 func (n *nodeBuilder) pop() {
+	l := len(n.stack)
+	if l == 1 {
+		n.root = n.stack[0]
+		n.stack = n.stack[:0]
+		return
+	}
 
+	node := n.stack[l-1]
+	n.stack = n.stack[:l-1]
+
+	collection := n.stack[l-2]
+	switch collection.Type {
+	case NODE_SEQUENCE:
+		collection.Children = append(collection.Children, node)
+	case NODE_MAP:
+		key := &n.keys[len(n.keys)-1]
+		if key.flag {
+			collection.Pairs = append(collection.Pairs, NodePair{key.node, node})
+			n.keys = n.keys[:len(n.keys)-1]
+		} else {
+			key.flag = true
+		}
+	default:
+		n.stack = n.stack[:0]
+	}
 }
 
-func (n *nodeBuilder) RegisterAnchor(anchor_t anchor, detail::node& node) {
-
+func (n *nodeBuilder) registerAnchor(anchor Anchor, node *Node) {
+	if anchor != NullAnchor {
+		node.Anchor = n.names[anchor]
+		for Anchor(len(n.anchors)) <= anchor {
+			n.anchors = append(n.anchors, nil)
+		}
+		n.anchors[anchor] = node
+	}
 }
-*/
@@ -0,0 +1,314 @@
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ParallelDecoder reads the documents of a YAML stream into Node trees like
+// Decoder, parsing several documents at once. The stream is split at its
+// document markers ("---" and "..." at the start of a line, which always
+// end the document before them) and the documents are handed to a pool of
+// workers; Decode returns them in stream order.
+//
+// Directives carry over to the documents that follow them without any of
+// their own, as they do with Decoder, and Marks are positions in the whole
+// stream. Since each document is parsed on its own, an *AliasError never
+// points to an anchor in an earlier document, and a quoted scalar running
+// into a document marker fails as cut off by the end of the input.
+//
+// Call Close to stop the workers when not reading the stream to its end.
+type ParallelDecoder struct {
+	// Workers is the number of documents parsed at once;
+	// runtime.GOMAXPROCS(0) if 0.
+	Workers int
+
+	// MaxInFlight bounds the documents read ahead of the one Decode returns,
+	// and so the memory held for them; 2 * Workers if 0.
+	MaxInFlight int
+
+	// AllowRecursion, Source, DefaultVersion and Warn are as for Decoder;
+	// Warn is called from the workers, possibly several at once.
+	AllowRecursion bool
+	Source         string
+	DefaultVersion Version
+	Warn           func(warning *ParseError)
+
+	reader  io.Reader
+	started bool
+	err     error
+
+	// a result channel per chunk of the stream, in stream order
+	pending chan chan chunkResult
+	done    chan struct{}
+	close   sync.Once
+
+	// the documents of the current chunk not returned yet
+	docs []*Node
+}
+
+// docChunk is a part of the stream holding at most a document.
+type docChunk struct {
+	data []byte
+	mark Mark // of data's start in the stream
+
+	// the directives in effect for the chunk, if it has none of its own
+	directives     []byte
+	directivesMark Mark
+
+	result chan chunkResult
+}
+
+type chunkResult struct {
+	docs []*Node
+	err  error
+}
+
+func NewParallelDecoder(reader io.Reader) *ParallelDecoder {
+	return &ParallelDecoder{reader: reader}
+}
+
+// Decode reads the next document. It returns io.EOF once the stream has no
+// documents left, and keeps returning the first error it met.
+func (d *ParallelDecoder) Decode() (*Node, error) {
+	if !d.started && d.err == nil {
+		d.start()
+	}
+
+	for len(d.docs) == 0 {
+		if d.err != nil {
+			return nil, d.err
+		}
+
+		result, ok := <-d.pending
+		if !ok {
+			d.err = io.EOF
+			break
+		}
+
+		r := <-result
+		if r.err != nil {
+			d.err = r.err
+			d.Close()
+		}
+		d.docs = r.docs
+	}
+
+	if len(d.docs) == 0 {
+		return nil, d.err
+	}
+	doc := d.docs[0]
+	d.docs = d.docs[1:]
+	return doc, nil
+}
+
+// Close stops reading the stream. Decode returns io.EOF afterwards, unless it
+// already failed.
+func (d *ParallelDecoder) Close() {
+	d.close.Do(func() {
+		if d.done != nil {
+			close(d.done)
+		}
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		d.docs = nil
+	})
+}
+
+func (d *ParallelDecoder) start() {
+	d.started = true
+
+	workers := d.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	maxInFlight := d.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 2 * workers
+	}
+
+	jobs := make(chan *docChunk)
+	d.pending = make(chan chan chunkResult, maxInFlight)
+	d.done = make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			parser := &Parser{}
+			for chunk := range jobs {
+				docs, err := d.decodeChunk(parser, chunk)
+				chunk.result <- chunkResult{docs, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(d.pending)
+		d.split(jobs)
+	}()
+}
+
+// split cuts the stream into chunks and hands them to the workers, queueing
+// their results in order. It returns once the stream is read or the decoder
+// closed.
+func (d *ParallelDecoder) split(jobs chan<- *docChunk) {
+	var directives []byte
+	var directivesMark Mark
+
+	// send queues a chunk; it returns false once the decoder is closed
+	send := func(chunk *docChunk) bool {
+		if len(chunk.data) == 0 {
+			return true
+		}
+
+		chunk.result = make(chan chunkResult, 1)
+		if chunk.directives == nil {
+			chunk.directives, chunk.directivesMark = directives, directivesMark
+		} else {
+			directives, directivesMark = bytes.Clone(chunk.directives), chunk.directivesMark
+			chunk.directives = nil
+		}
+
+		select {
+		case d.pending <- chunk.result:
+		case <-d.done:
+			return false
+		}
+		select {
+		case jobs <- chunk:
+		case <-d.done:
+			return false
+		}
+		return true
+	}
+
+	reader := bufio.NewReader(d.reader)
+	mark := Mark{}
+	chunk := &docChunk{mark: mark}
+	started, ended := false, false // the chunk has document content, or a "..."
+
+	for {
+		head, _ := reader.Peek(4)
+		if mark.Pos == 0 {
+			head = bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF"))
+		}
+
+		// a new chunk starts at a document start after some content, or
+		// after a document end and any more "...". Directives only come at
+		// the start of the stream or after a document end, so a chunk starts
+		// with them; a line starting with "%" anywhere else is content.
+		if started && isDocumentMarker(head, "---") || ended && !isDocumentMarker(head, "...") && !isBlankLine(head) {
+			if !send(chunk) {
+				return
+			}
+			chunk = &docChunk{mark: mark}
+			started, ended = false, false
+		}
+		isDirective := len(head) > 0 && head[0] == '%' && !started
+
+		// read the line
+		lineStart := len(chunk.data)
+		var err error
+		for {
+			var part []byte
+			part, err = reader.ReadSlice('\n')
+			chunk.data = append(chunk.data, part...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		line := chunk.data[lineStart:]
+
+		switch {
+		case len(line) == 0:
+		case isDirective:
+			chunk.directives = chunk.data
+			chunk.directivesMark = chunk.mark
+		case isDocumentMarker(head, "..."):
+			ended = true
+		case !isBlankLine(line):
+			started = true
+		}
+
+		mark.Pos += len(line)
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			mark.Line++
+		}
+
+		if err == io.EOF {
+			send(chunk)
+			return
+		} else if err != nil {
+			result := make(chan chunkResult, 1)
+			result <- chunkResult{nil, &ParseError{mark, err.Error()}}
+			if send(chunk) {
+				select {
+				case d.pending <- result:
+				case <-d.done:
+				}
+			}
+			return
+		}
+	}
+}
+
+// isDocumentMarker reports whether a line starts with a document marker.
+func isDocumentMarker(head []byte, marker string) bool {
+	if !bytes.HasPrefix(head, []byte(marker)) {
+		return false
+	}
+	return len(head) == len(marker) || bytes.IndexByte([]byte(" \t\r\n"), head[len(marker)]) >= 0
+}
+
+// isBlankLine reports whether a line holds nothing but whitespace or a
+// comment.
+func isBlankLine(line []byte) bool {
+	line = bytes.TrimLeft(line, " \t\r\n")
+	return len(line) == 0 || line[0] == '#'
+}
+
+// decodeChunk parses the documents of a chunk with a worker's parser.
+func (d *ParallelDecoder) decodeChunk(parser *Parser, chunk *docChunk) ([]*Node, error) {
+	loadAt(parser, chunk.data, chunk.mark)
+	if chunk.directives != nil {
+		// the directives are all the other parser reads, and it leaves
+		// warning about them to the chunk they came from
+		other := &Parser{DefaultVersion: d.DefaultVersion}
+		loadAt(other, chunk.directives, chunk.directivesMark)
+		if _, err := other.HandleNextDocument(newNodeBuilder()); err != nil {
+			return nil, err
+		}
+		parser.directives = other.directives
+	}
+
+	decoder := &Decoder{
+		parser:         parser,
+		AllowRecursion: d.AllowRecursion,
+		Source:         d.Source,
+		DefaultVersion: d.DefaultVersion,
+		Warn:           d.Warn,
+	}
+	var docs []*Node
+	for {
+		doc, err := decoder.Decode()
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// loadAt resets a parser to read the part of a stream starting at mark,
+// which is at the start of a line.
+func loadAt(parser *Parser, data []byte, mark Mark) {
+	parser.Load(bytes.NewReader(data))
+	parser.scanner.input.mark.Pos += mark.Pos
+	parser.scanner.input.base += mark.Pos
+	parser.scanner.input.mark.Line = mark.Line
+}
@@ -0,0 +1,48 @@
+package yaml
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// decodedText emits each document decode returns, and the error it ends on.
+func decodedText(t *testing.T, decode func() (*Node, error)) []string {
+	t.Helper()
+	var out []string
+	for {
+		doc, err := decode()
+		if err == io.EOF {
+			return out
+		} else if err != nil {
+			return append(out, "error: "+err.Error())
+		}
+		out = append(out, emitText(t, doc))
+	}
+}
+
+func TestParallelDecoder(t *testing.T) {
+	inputs := []string{
+		"a: 1\n--- b\n...\n--- [c]\n",
+		"# comment\n%YAML 1.1\n---\nyes\n...\n%YAML 1.2\n--- yes\n",
+		"%TAG !e! tag:example.com,2000:\n--- !e!a x\n--- !e!b y\n",
+		"a\n...\n...\n\nb\n",
+		// a "%" that isn't at the start of the stream or after a "..." is
+		// content
+		"--- a\n%b\n",
+		"--- 'a\n%b'\n",
+		"---\n- a\n%b\n--- c\n",
+		"a: [1\n--- b\n",
+	}
+
+	for _, text := range inputs {
+		expected := decodedText(t, NewDecoder(strings.NewReader(text)).Decode)
+		decoder := NewParallelDecoder(strings.NewReader(text))
+		out := decodedText(t, decoder.Decode)
+		decoder.Close()
+
+		if strings.Join(out, "---\n") != strings.Join(expected, "---\n") {
+			t.Errorf("%q: expected %q, got %q", text, expected, out)
+		}
+	}
+}
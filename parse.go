@@ -0,0 +1,31 @@
+package yaml
+
+import (
+	"io"
+)
+
+// Load parses the first document in reader into a Node tree. An empty stream
+// loads as a null node.
+func Load(reader io.Reader) (*Node, error) {
+	node, err := NewDecoder(reader).Decode()
+	if err == io.EOF {
+		return &Node{Type: NODE_NULL, Mark: NullMark}, nil
+	}
+	return node, err
+}
+
+// LoadAll parses every document in reader.
+func LoadAll(reader io.Reader) (docs []*Node, err error) {
+	decoder := NewDecoder(reader)
+	for {
+		node, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, node)
+	}
+	return
+}
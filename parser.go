@@ -1,6 +1,7 @@
 package yaml
 
 import (
+	"context"
 	"fmt"
 	"io"
 )
@@ -8,6 +9,21 @@ import (
 type Parser struct {
 	scanner    *Scanner
 	directives *Directives
+	doc        *singleDocParser
+
+	// where the anchors of the previous document were defined; only that
+	// document's are kept, so long streams don't pile them up
+	anchors map[string]Mark
+
+	// DefaultVersion is the YAML version of documents without a %YAML
+	// directive; 1.2 if zero. Setting it to 1.1 reads legacy files the way
+	// they were written.
+	DefaultVersion Version
+
+	// Warn, if set, is called with the problems in the input that don't
+	// stop it from being read, such as a %YAML directive for a later 1.x
+	// version than 1.2, which is read as 1.2.
+	Warn func(warning *ParseError)
 }
 
 type ParseError struct {
@@ -20,10 +36,85 @@ func (e *ParseError) String() string {
 	return fmt.Sprintf("%v - %v", e.mark, e.error)
 }
 
+func (e *ParseError) Error() string {
+	return e.String()
+}
+
+// Mark returns where in the input the error occurred.
+func (e *ParseError) Mark() Mark {
+	return e.mark
+}
+
+// Message returns the error message without the mark.
+func (e *ParseError) Message() string {
+	return e.error
+}
+
+// AliasError reports an alias to an anchor that is not defined before it in
+// the same document. Anchors never carry over from one document to the next.
+type AliasError struct {
+	Mark Mark // of the alias
+	Name string
+
+	// Later is where an anchor of that name is defined further on in the
+	// document, within anchorLookahead tokens of the alias, and
+	// PreviousDocument where one was defined in the previous document;
+	// NullMark if there is none.
+	Later            Mark
+	PreviousDocument Mark
+
+	// Suggestion is the defined anchor with the most similar name, if any.
+	Suggestion string
+}
+
+func newAliasError(mark Mark, name string) *AliasError {
+	return &AliasError{Mark: mark, Name: name, Later: NullMark, PreviousDocument: NullMark}
+}
+
+func (e *AliasError) Error() string {
+	return fmt.Sprintf("%v - %v", e.Mark, e.Message())
+}
+
+// Message returns the error message without the mark.
+func (e *AliasError) Message() string {
+	msg := ERR_UNKNOWN_ANCHOR + e.Name
+	if e.Later != NullMark {
+		msg += fmt.Sprintf(" (it is defined later, at %v; an anchor must come before its aliases)", e.Later)
+	} else if e.PreviousDocument != NullMark {
+		msg += fmt.Sprintf(" (it is defined in an earlier document, at %v; anchors do not carry over between documents)", e.PreviousDocument)
+	}
+	if len(e.Suggestion) > 0 {
+		msg += fmt.Sprintf("; did you mean %q?", e.Suggestion)
+	}
+	return msg
+}
+
+// CanceledError reports parsing stopped because its context was done. It
+// unwraps to the context's error, so errors.Is(err, context.Canceled) holds.
+type CanceledError struct {
+	Mark Mark // how far the parser got
+	Err  error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%v - %v", e.Mark, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
 func NewParser(reader io.Reader) *Parser {
+	return NewParserSize(reader, DefaultMaxLookahead)
+}
+
+// NewParserSize returns a parser whose scanner keeps scalars of up to
+// maxLookahead bytes in its read buffer; see NewScannerSize.
+func NewParserSize(reader io.Reader, maxLookahead int) *Parser {
 	return &Parser{
-		scanner: NewScanner(reader),
+		scanner: NewScannerSize(reader, maxLookahead),
 		directives: NewDirectives(),
+		anchors: make(map[string]Mark),
 	}
 }
 
@@ -31,11 +122,29 @@ func (p *Parser) IsValid() bool {
 	return !p.scanner.Empty()
 }
 
+// Load resets the parser to read from reader. It keeps its lookahead and the
+// memory it has allocated for tokens and bookkeeping, so reusing a parser
+// for many small streams allocates less than making a new one for each.
 func (p *Parser) Load(reader io.Reader) {
-	p.scanner = NewScanner(reader)
+	if p.scanner != nil {
+		p.scanner.reset(reader)
+	} else {
+		p.scanner = NewScanner(reader)
+	}
 	p.directives = NewDirectives()
+	if p.anchors != nil {
+		clear(p.anchors)
+	} else {
+		p.anchors = make(map[string]Mark)
+	}
 }
 
+// HandleNextDocument parses the next document of the stream, reporting it to
+// evtHandler. It returns false once there are no documents left.
+//
+// Anchors are scoped to their document: Anchor values restart at 1 with each
+// document and an alias can only refer to an anchor defined before it in the
+// same document. Other aliases fail with an *AliasError.
 func (p *Parser) HandleNextDocument(evtHandler EventHandler) (success bool, err error) {
 	if p.scanner == nil {
 		return
@@ -45,6 +154,10 @@ func (p *Parser) HandleNextDocument(evtHandler EventHandler) (success bool, err
 	defer func() {
 		if r := recover(); r != nil {
 			success = false
+			if aliasErr, ok := r.(*AliasError); ok {
+				p.locateAnchor(aliasErr)
+			}
+
 			var ok bool
 			if err, ok = r.(error); !ok {
 				err = fmt.Errorf("yamlgo: %v", r)
@@ -52,27 +165,95 @@ func (p *Parser) HandleNextDocument(evtHandler EventHandler) (success bool, err
 		}
 	}()
 
+	p.useVersion()
 	p.parseDirectives()
 	if p.scanner.Empty() {
 		return
 	}
 
-	sdp := newSingleDocParser(p.scanner, p.directives)
-	sdp.handleDocument(evtHandler)
+	if p.doc == nil {
+		p.doc = newSingleDocParser(p.scanner, p.directives)
+	} else {
+		p.doc.reset(p.scanner, p.directives)
+	}
+	p.doc.handleDocument(evtHandler)
+	clear(p.anchors)
+	for name, mark := range p.doc.anchorMarks {
+		p.anchors[name] = mark
+	}
+
 	success = true
 	return
 }
 
-func (p *Parser) PrintTokens() (output string) {
+// HandleNextDocumentContext is HandleNextDocument, stopping with a
+// *CanceledError once ctx is done. The context is checked every few hundred
+// tokens and nodes, so a cancelled parse returns promptly even in the middle
+// of a large document; the parser is not usable afterwards.
+func (p *Parser) HandleNextDocumentContext(ctx context.Context, evtHandler EventHandler) (bool, error) {
 	if p.scanner == nil {
-		return
+		return false, nil
+	} else if err := ctx.Err(); err != nil {
+		return false, &CanceledError{p.scanner.Mark(), err}
 	}
 
-	for !p.scanner.Empty() {
-		output += p.scanner.Peek().String() + "\n"
+	p.scanner.ctx = ctx
+	defer func() {
+		p.scanner.ctx = nil
+	}()
+	return p.HandleNextDocument(evtHandler)
+}
+
+// anchorLookahead is how many tokens locateAnchor scans past an alias for
+// its anchor, so that a bad alias near the start of a large input doesn't
+// cost a scan of the whole of it.
+const anchorLookahead = 4096
+
+// locateAnchor completes an AliasError with where the missing anchor is
+// defined out of the alias's reach: further on in the document, or in an
+// earlier one. It runs under the scanner's context, if any, and gives up
+// after anchorLookahead tokens.
+func (p *Parser) locateAnchor(e *AliasError) {
+	if mark, ok := p.anchors[e.Name]; ok {
+		e.PreviousDocument = mark
+	}
+
+	// the rest of the document may not scan either
+	defer func() {
+		recover()
+	}()
+
+	for n := 0; n < anchorLookahead && !p.scanner.Empty(); n++ {
+		token := p.scanner.Peek()
+		switch token.Type {
+		case TOKEN_DIRECTIVE, TOKEN_DOC_START, TOKEN_DOC_END:
+			return
+		case TOKEN_ANCHOR:
+			if token.Value == e.Name {
+				e.Later = token.Mark
+				return
+			}
+		}
 		p.scanner.Pop()
 	}
-	return
+}
+
+// useVersion reads on under the YAML version of the current directives, or
+// the default version if they have no %YAML directive.
+func (p *Parser) useVersion() {
+	if p.directives.Version.IsDefault {
+		p.directives.Version = Version{true, 1, 2}
+		if p.DefaultVersion.Major > 0 {
+			p.directives.Version = Version{true, p.DefaultVersion.Major, p.DefaultVersion.Minor}
+		}
+	}
+	p.scanner.setVersion(p.directives.Version)
+}
+
+func (p *Parser) warn(mark Mark, msg string) {
+	if p.Warn != nil {
+		p.Warn(&ParseError{mark, msg})
+	}
 }
 
 func (p *Parser) parseDirectives() {
@@ -90,6 +271,10 @@ func (p *Parser) parseDirectives() {
 
 		readDirective = true
 		p.handleDirective(token)
+
+		// the directive is the only token queued, so the next one is scanned
+		// under the version it sets
+		p.useVersion()
 		p.scanner.Pop()
 	}
 }
@@ -112,6 +297,8 @@ func (p *Parser) handleYamlDirective(token *Token) {
 		panic(&ParseError{token.Mark, ERR_YAML_VERSION + " " + token.Params[0]})
 	} else if p.directives.Version.Major > 1 {
 		panic(&ParseError{token.Mark, ERR_YAML_MAJOR_VERSION})
+	} else if p.directives.Version.Major == 1 && p.directives.Version.Minor > 2 {
+		p.warn(token.Mark, ERR_YAML_MINOR_VERSION)
 	}
 
 	p.directives.Version.IsDefault = false
@@ -123,9 +310,15 @@ func (p *Parser) handleTagDirective(token *Token) {
 	}
 
 	handle := token.Params[0]
-	if _, ok := p.directives.Tags[handle]; !ok {
+	if !isURI(token.Params[1]) {
+		panic(&ParseError{token.Mark, ERR_CHAR_IN_TAG_PREFIX})
+	}
+	if _, ok := p.directives.Tags[handle]; ok {
 		panic(&ParseError{token.Mark, ERR_REPEATED_TAG_DIRECTIVE})
 	} else {
+		if p.directives.Tags == nil {
+			p.directives.Tags = make(map[string]string)
+		}
 		p.directives.Tags[handle] = token.Params[1] // token.Params[1] == prefix
 	}
 }
@@ -0,0 +1,53 @@
+package yaml
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// aliasError parses the first document of text, expecting an *AliasError.
+func aliasError(t *testing.T, ctx context.Context, text string) *AliasError {
+	t.Helper()
+	_, err := NewParser(strings.NewReader(text)).HandleNextDocumentContext(ctx, BaseHandler{})
+	var aliasErr *AliasError
+	if !errors.As(err, &aliasErr) {
+		t.Fatalf("expected an *AliasError, got %v", err)
+	}
+	return aliasErr
+}
+
+// canceledAfter is a context that is done after n looks at it.
+type canceledAfter struct {
+	context.Context
+	n int
+}
+
+func (c *canceledAfter) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestAliasErrorLater(t *testing.T) {
+	items := func(n int) string {
+		return strings.Repeat("- x\n", n)
+	}
+
+	if e := aliasError(t, context.Background(), "- *a\n"+items(10)+"- &a y\n"); e.Later.Line != 11 {
+		t.Errorf("expected the anchor to be found on line 11, got %+v", e.Later)
+	}
+
+	// the scan for the anchor stops after anchorLookahead tokens, or once the
+	// context is done
+	text := "- *a\n" + items(anchorLookahead) + "- &a y\n"
+	if e := aliasError(t, context.Background(), text); e.Later != NullMark {
+		t.Errorf("expected the anchor to be out of reach, got %+v", e.Later)
+	}
+	text = "- *a\n" + items(anchorLookahead/4) + "- &a y\n"
+	if e := aliasError(t, &canceledAfter{context.Background(), 1}, text); e.Later != NullMark {
+		t.Errorf("expected the scan to stop with the context, got %+v", e.Later)
+	}
+}
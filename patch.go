@@ -0,0 +1,351 @@
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchError reports a JSON Patch operation that could not be applied.
+type PatchError struct {
+	Op     int    // index of the operation in the patch
+	Name   string // of the operation, e.g. "replace"
+	Path   string
+	Mark   Mark // of the operation in the patch
+	Target Mark // of the deepest node of the document on the operation's path
+	Msg    string
+}
+
+func (e *PatchError) Error() string {
+	msg := fmt.Sprintf("patch operation %d", e.Op)
+	if len(e.Name) > 0 {
+		msg += fmt.Sprintf(" (%v %q)", e.Name, e.Path)
+	}
+	msg += fmt.Sprintf(" at %v - %v", e.Mark, e.Msg)
+	if e.Target != NullMark {
+		msg += fmt.Sprintf("; target at %v", e.Target)
+	}
+	return msg
+}
+
+// ApplyPatch applies an RFC 6902 JSON Patch to doc and returns the patched
+// document. The patch is a sequence of operation maps, which may be
+// written in YAML as well as JSON:
+//
+//   - op: replace
+//     path: /spec/replicas
+//     value: 3
+//   - {op: add, path: /spec/ports/-, value: 443}
+//
+// Paths are JSON Pointers (RFC 6901), whose tokens match map keys by their
+// text. Values are compared by their resolved values for "test", as in
+// Diff. The patch applies to a copy of doc, so doc is left alone, also when
+// an operation fails. A node that doc shares between places through an
+// alias is copied before it is changed, so each change shows at its own
+// path only.
+func ApplyPatch(doc *Node, patch *Node) (*Node, error) {
+	if patch.Type != NODE_SEQUENCE {
+		return nil, &PatchError{Op: -1, Mark: patch.Mark, Target: NullMark, Msg: ERR_PATCH_NOT_SEQ}
+	}
+
+	root := doc.Clone()
+	for i, op := range patch.Children {
+		var err *PatchError
+		if root, err = applyOperation(root, op); err != nil {
+			err.Op = i
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// patchFailure is raised while applying an operation, with the deepest
+// node reached on its path.
+type patchFailure struct {
+	target *Node
+	msg    string
+}
+
+func applyOperation(root *Node, op *Node) (ret *Node, err *PatchError) {
+	err = &PatchError{Mark: op.Mark, Target: NullMark}
+
+	// Handle operation panics.
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(*patchFailure)
+			if !ok {
+				panic(r)
+			}
+			if failure.target != nil {
+				err.Target = failure.target.Mark
+			}
+			err.Msg = failure.msg
+			ret = nil
+		}
+	}()
+
+	if op.Type != NODE_MAP {
+		panic(&patchFailure{nil, ERR_PATCH_NOT_MAP})
+	}
+	err.Name = patchField(op, "op", true).Value
+	err.Path = patchField(op, "path", true).Value
+	path := parsePointer(err.Path)
+
+	switch err.Name {
+	case "add":
+		root = patchAdd(root, path, patchField(op, "value", false).Clone())
+	case "remove":
+		patchRemove(root, path)
+	case "replace":
+		root = patchReplace(root, path, patchField(op, "value", false).Clone())
+	case "move":
+		fromText := patchField(op, "from", true).Value
+		from := parsePointer(fromText)
+		if strings.HasPrefix(err.Path, fromText+"/") {
+			panic(&patchFailure{nil, ERR_PATCH_MOVE_INTO_CHILD})
+		}
+		value := patchRemove(root, from)
+		root = patchAdd(root, path, value)
+	case "copy":
+		from := parsePointer(patchField(op, "from", true).Value)
+		value := patchGet(root, from).Clone()
+		root = patchAdd(root, path, value)
+	case "test":
+		value := patchField(op, "value", false)
+		target := patchGet(root, path)
+		if compareKey(target, make(map[*Node]bool)) != compareKey(value, make(map[*Node]bool)) {
+			panic(&patchFailure{target, ERR_PATCH_TEST_FAILED})
+		}
+	default:
+		panic(&patchFailure{nil, ERR_PATCH_UNKNOWN_OP + err.Name})
+	}
+	return root, nil
+}
+
+// patchField returns a field of an operation, which must be a scalar if
+// it's text.
+func patchField(op *Node, name string, text bool) *Node {
+	field := op.Get(name)
+	if field == nil {
+		panic(&patchFailure{nil, ERR_PATCH_MISSING_FIELD + name})
+	} else if text && field.Type != NODE_SCALAR {
+		panic(&patchFailure{nil, ERR_PATCH_FIELD_TYPE + name})
+	}
+	return field
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits a JSON Pointer into its unescaped tokens.
+func parsePointer(pointer string) []string {
+	if len(pointer) == 0 {
+		return nil
+	} else if pointer[0] != '/' {
+		panic(&patchFailure{nil, ERR_PATCH_BAD_POINTER + pointer})
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 >= len(token) || token[j+1] != '0' && token[j+1] != '1') {
+				panic(&patchFailure{nil, ERR_PATCH_BAD_POINTER + pointer})
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens
+}
+
+// patchGet returns the node at path, which must exist.
+func patchGet(root *Node, path []string) *Node {
+	node := root
+	for _, token := range path {
+		switch node.Type {
+		case NODE_MAP:
+			child := node.Get(token)
+			if child == nil {
+				panic(&patchFailure{node, ERR_PATCH_NO_KEY + token})
+			}
+			node = child
+		case NODE_SEQUENCE:
+			node = node.Children[patchIndex(node, token, false)]
+		default:
+			panic(&patchFailure{node, ERR_PATCH_NOT_CONTAINER + token})
+		}
+	}
+	return node
+}
+
+// patchParent returns the parent of the node at path, whose own parent
+// must exist, copying each node on the way that the document shares with
+// another place so that changing the parent changes the document at path
+// only.
+func patchParent(root *Node, path []string) *Node {
+	owner := newNodeOwner(root)
+	node := root
+	for _, token := range path[:len(path)-1] {
+		switch node.Type {
+		case NODE_MAP:
+			i := patchKey(node, token)
+			if i < 0 {
+				panic(&patchFailure{node, ERR_PATCH_NO_KEY + token})
+			}
+			node.Pairs[i].Value = owner.own(node.Pairs[i].Value)
+			node = node.Pairs[i].Value
+		case NODE_SEQUENCE:
+			i := patchIndex(node, token, false)
+			node.Children[i] = owner.own(node.Children[i])
+			node = node.Children[i]
+		default:
+			panic(&patchFailure{node, ERR_PATCH_NOT_CONTAINER + token})
+		}
+	}
+	return node
+}
+
+// patchKey returns the index of the entry of a map with the key token, or
+// -1.
+func patchKey(mapping *Node, token string) int {
+	for i, pair := range mapping.Pairs {
+		if pair.Key.Type == NODE_SCALAR && pair.Key.Value == token {
+			return i
+		}
+	}
+	return -1
+}
+
+// patchIndex parses a token indexing a sequence. Unless appending, the index
+// must be of an item; when appending it may also be the length, or "-".
+func patchIndex(seq *Node, token string, appending bool) int {
+	if appending && token == "-" {
+		return len(seq.Children)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		panic(&patchFailure{seq, ERR_PATCH_BAD_INDEX + token})
+	}
+	if i > len(seq.Children) || i == len(seq.Children) && !appending {
+		panic(&patchFailure{seq, ERR_PATCH_INDEX_RANGE + token})
+	}
+	return i
+}
+
+// patchAdd adds value at path and returns the root, which value replaces
+// for an empty path.
+func patchAdd(root *Node, path []string, value *Node) *Node {
+	if len(path) == 0 {
+		return value
+	}
+
+	parent, token := patchParent(root, path), path[len(path)-1]
+	switch parent.Type {
+	case NODE_MAP:
+		if i := patchKey(parent, token); i >= 0 {
+			parent.Pairs[i].Value = value
+			return root
+		}
+		key := &Node{Type: NODE_SCALAR, Mark: NullMark, Tag: "!", Value: token}
+		parent.Pairs = append(parent.Pairs, NodePair{key, value})
+	case NODE_SEQUENCE:
+		i := patchIndex(parent, token, true)
+		parent.Children = append(parent.Children, nil)
+		copy(parent.Children[i+1:], parent.Children[i:])
+		parent.Children[i] = value
+	default:
+		panic(&patchFailure{parent, ERR_PATCH_NOT_CONTAINER + token})
+	}
+	return root
+}
+
+// patchReplace replaces the node at path, which must exist, and returns
+// the root.
+func patchReplace(root *Node, path []string, value *Node) *Node {
+	if len(path) == 0 {
+		return value
+	}
+
+	parent, token := patchParent(root, path), path[len(path)-1]
+	switch parent.Type {
+	case NODE_MAP:
+		if i := patchKey(parent, token); i >= 0 {
+			parent.Pairs[i].Value = value
+			return root
+		}
+		panic(&patchFailure{parent, ERR_PATCH_NO_KEY + token})
+	case NODE_SEQUENCE:
+		parent.Children[patchIndex(parent, token, false)] = value
+		return root
+	}
+	panic(&patchFailure{parent, ERR_PATCH_NOT_CONTAINER + token})
+}
+
+// patchRemove removes the node at path, which must exist, and returns it.
+func patchRemove(root *Node, path []string) *Node {
+	if len(path) == 0 {
+		panic(&patchFailure{root, ERR_PATCH_REMOVE_ROOT})
+	}
+
+	parent, token := patchParent(root, path), path[len(path)-1]
+	switch parent.Type {
+	case NODE_MAP:
+		if i := patchKey(parent, token); i >= 0 {
+			value := parent.Pairs[i].Value
+			parent.Pairs = append(parent.Pairs[:i], parent.Pairs[i+1:]...)
+			return value
+		}
+		panic(&patchFailure{parent, ERR_PATCH_NO_KEY + token})
+	case NODE_SEQUENCE:
+		i := patchIndex(parent, token, false)
+		value := parent.Children[i]
+		parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+		return value
+	}
+	panic(&patchFailure{parent, ERR_PATCH_NOT_CONTAINER + token})
+}
+
+// MergePatch applies an RFC 7386 merge patch to doc and returns the result:
+// each entry of a map patch sets the key's value in doc, merging maps
+// recursively, and an entry with a null value removes the key. A patch that
+// isn't a map replaces doc. Keys match by their resolved values. Neither
+// doc nor patch is changed, and a map that doc shares between places
+// through an alias is copied before it is patched, so the patch changes
+// the place it names only.
+func MergePatch(doc *Node, patch *Node) *Node {
+	root := doc.Clone()
+	return mergePatch(newNodeOwner(root), root, patch)
+}
+
+func mergePatch(owner *nodeOwner, target *Node, patch *Node) *Node {
+	if patch.Type != NODE_MAP {
+		return patch.Clone()
+	}
+	if target == nil || target.Type != NODE_MAP {
+		target = &Node{Type: NODE_MAP, Mark: patch.Mark, Tag: "?"}
+	}
+	target = owner.own(target)
+
+	keys := make(map[*Node]bool)
+	for _, entry := range patch.Pairs {
+		key := compareKey(entry.Key, keys)
+
+		i := 0
+		for ; i < len(target.Pairs); i++ {
+			if compareKey(target.Pairs[i].Key, keys) == key {
+				break
+			}
+		}
+
+		switch {
+		case entry.Value.ResolvedTag() == TAG_NULL:
+			if i < len(target.Pairs) {
+				target.Pairs = append(target.Pairs[:i], target.Pairs[i+1:]...)
+			}
+		case i < len(target.Pairs):
+			target.Pairs[i].Value = mergePatch(owner, target.Pairs[i].Value, entry.Value)
+		default:
+			target.Pairs = append(target.Pairs, NodePair{entry.Key.Clone(), mergePatch(owner, nil, entry.Value)})
+		}
+	}
+	return target
+}
@@ -0,0 +1,90 @@
+package yaml
+
+import (
+	"bytes"
+	"testing"
+)
+
+func emitText(t *testing.T, node *Node) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Emit(&out, node); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := mustLoad(t, "spec:\n  replicas: 1\n  ports: [80]\n")
+	patch := mustLoad(t, `- {op: replace, path: /spec/replicas, value: 3}
+- {op: add, path: /spec/ports/-, value: 443}
+- {op: add, path: /spec/paused, value: true}
+- {op: copy, from: /spec/ports/0, path: /spec/ports/0}
+- {op: move, from: /spec/paused, path: /paused}
+- {op: test, path: /spec/replicas, value: 0x3}
+- {op: remove, path: /spec/ports/1}
+`)
+
+	patched, err := ApplyPatch(doc, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, "patched", patched, "spec: {replicas: 3, ports: [80, 443]}\npaused: true\n")
+	checkValues(t, "original", doc, "spec: {replicas: 1, ports: [80]}\n")
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	doc := mustLoad(t, "a: [1]\n")
+	for _, patch := range []string{
+		"- {op: remove, path: /b}",
+		"- {op: replace, path: /a/1, value: 2}",
+		"- {op: test, path: /a/0, value: '1'}",
+		"- {op: add, path: /a/0/x, value: 2}",
+		"- {op: move, from: /a, path: /a/0}",
+		"- {op: frob, path: /a}",
+	} {
+		if _, err := ApplyPatch(doc, mustLoad(t, patch)); err == nil {
+			t.Errorf("%v: expected an error", patch)
+		}
+	}
+}
+
+// Nodes shared through aliases are copied before they are changed, so a
+// change shows at the path it names only.
+func TestPatchAliases(t *testing.T) {
+	const src = "base: &b {x: 1, deep: {y: 2}}\nprod: *b\n"
+
+	tests := []struct {
+		patch, expected string
+	}{
+		{"- {op: replace, path: /prod/x, value: 2}",
+			"base: {x: 1, deep: {y: 2}}\nprod: {x: 2, deep: {y: 2}}\n"},
+		{"- {op: replace, path: /base/deep/y, value: 3}",
+			"base: {x: 1, deep: {y: 3}}\nprod: {x: 1, deep: {y: 2}}\n"},
+		{"- {op: remove, path: /prod/x}\n- {op: add, path: /prod/z, value: 4}",
+			"base: {x: 1, deep: {y: 2}}\nprod: {deep: {y: 2}, z: 4}\n"},
+	}
+
+	for _, test := range tests {
+		doc := mustLoad(t, src)
+		patched, err := ApplyPatch(doc, mustLoad(t, test.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkValues(t, test.patch, patched, test.expected)
+	}
+
+	doc := mustLoad(t, src)
+	patched := MergePatch(doc, mustLoad(t, "prod: {x: 2, deep: {y: 3}}"))
+	checkValues(t, "merge patch", patched, "base: {x: 1, deep: {y: 2}}\nprod: {x: 2, deep: {y: 3}}\n")
+	checkValues(t, "merge patch", doc, src)
+}
+
+// checkValues compares a document with the expected one by value, aliases
+// expanded.
+func checkValues(t *testing.T, name string, doc *Node, expected string) {
+	t.Helper()
+	if changes := Diff(mustLoad(t, expected), doc); len(changes) > 0 {
+		t.Errorf("%v: expected\n%v\ngot\n%v", name, expected, emitText(t, doc))
+	}
+}
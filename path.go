@@ -0,0 +1,185 @@
+package yaml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PathElement is a step from a collection to one of its values: an index
+// into a sequence, or the key of a map entry. An entry whose key isn't a
+// scalar is reached by its position in the map instead, with IsEntry set.
+type PathElement struct {
+	Key     string
+	Index   int
+	IsIndex bool
+	IsEntry bool
+}
+
+// Path locates a node within a document, starting from the root.
+type Path []PathElement
+
+// String renders a path like spec.containers[0].image. Keys that aren't
+// plain identifiers are quoted, as in metadata.labels["app.kubernetes.io/name"],
+// and the position of an entry whose key isn't a scalar is written as [#1].
+func (p Path) String() string {
+	var b strings.Builder
+	for i, elem := range p {
+		switch {
+		case elem.IsIndex:
+			b.WriteString("[" + strconv.Itoa(elem.Index) + "]")
+		case elem.IsEntry:
+			b.WriteString("[#" + strconv.Itoa(elem.Index) + "]")
+		case isPathIdentifier(elem.Key):
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(elem.Key)
+		default:
+			b.WriteString("[" + strconv.Quote(elem.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+// child returns a copy of the path extended by elem, so that paths handed
+// out to callers never share their backing arrays.
+func (p Path) child(elem PathElement) Path {
+	path := make(Path, len(p), len(p)+1)
+	copy(path, p)
+	return append(path, elem)
+}
+
+func isPathIdentifier(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i, ch := range key {
+		switch {
+		case ch == '_' || ch == '-' && i > 0:
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z':
+		case ch >= '0' && ch <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// pathTracker follows the path to each node through a document's events,
+// giving them the paths Walk would. Nodes in a map key get the path of the
+// map, and the key's value the path of the entry; an entry whose key isn't a
+// scalar (or an alias to one) is reached by its position in the map.
+type pathTracker struct {
+	frames []pathFrame
+
+	// the values of anchored scalars, for aliases used as keys
+	scalars map[Anchor]string
+
+	// the document's YAML version, under which plain keys are rewritten to
+	// their YAML 1.2 spelling as in a Node tree
+	version Version
+}
+
+// pathFrame is a collection open around the current node.
+type pathFrame struct {
+	path  Path
+	isMap bool
+	isKey bool // the collection is in a map key
+
+	index int         // of the next item of a sequence or entry of a map
+	elem  PathElement // of the map entry whose value is next
+	value bool        // the next node of a map is a value
+}
+
+// entry returns the path element of the map entry at index whose key is
+// the current node; key is its value, when it is a scalar.
+func (f *pathFrame) entry(key string, isScalar bool) PathElement {
+	if !isScalar {
+		return PathElement{Index: f.index, IsEntry: true}
+	}
+	return PathElement{Key: key, Index: f.index}
+}
+
+func (t *pathTracker) reset() {
+	t.frames = t.frames[:0]
+	clear(t.scalars)
+	t.version = Version{}
+}
+
+// current returns the path of the node the next event starts, and whether
+// that node is in a map key.
+func (t *pathTracker) current() (path Path, isKey bool) {
+	f := t.top()
+	switch {
+	case f == nil:
+		return Path{}, false
+	case f.isKey:
+		return f.path, true
+	case !f.isMap:
+		return f.path.child(PathElement{Index: f.index, IsIndex: true}), false
+	case f.value:
+		return f.path.child(f.elem), false
+	default:
+		return f.path, true
+	}
+}
+
+// top returns the innermost open collection, or nil at the root.
+func (t *pathTracker) top() *pathFrame {
+	if len(t.frames) == 0 {
+		return nil
+	}
+	return &t.frames[len(t.frames)-1]
+}
+
+// scalar records an anchored scalar for aliases to it, and returns the key
+// it makes: its value in YAML 1.2 terms, unless it is a plain null, which
+// isn't a scalar to Walk either.
+func (t *pathTracker) scalar(anchor Anchor, tag string, value string) (key string, isScalar bool) {
+	tag, value = coreScalar(tag, value, t.version)
+	if ResolveTag(tag, value) == TAG_NULL {
+		return "", false
+	}
+	if anchor != NullAnchor {
+		if t.scalars == nil {
+			t.scalars = make(map[Anchor]string)
+		}
+		t.scalars[anchor] = value
+	}
+	return value, true
+}
+
+// start enters a collection starting at the current node.
+func (t *pathTracker) start(isMap bool) {
+	path, isKey := t.current()
+	t.frames = append(t.frames, pathFrame{path: path, isMap: isMap, isKey: isKey})
+}
+
+// end leaves the innermost collection, and moves past it.
+func (t *pathTracker) end() {
+	t.frames = t.frames[:len(t.frames)-1]
+	t.next("", false)
+}
+
+// next moves past the current node; key is its value, when it is a scalar
+// that may be a map key.
+func (t *pathTracker) next(key string, isScalar bool) {
+	f := t.top()
+	switch {
+	case f == nil || f.isKey:
+	case !f.isMap:
+		f.index++
+	case f.value:
+		f.index++
+		f.value = false
+	default:
+		f.elem, f.value = f.entry(key, isScalar), true
+	}
+}
+
+// alias returns the value of the scalar an alias refers to, and whether it
+// is one.
+func (t *pathTracker) alias(anchor Anchor) (key string, isScalar bool) {
+	key, isScalar = t.scalars[anchor]
+	return key, isScalar
+}
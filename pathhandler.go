@@ -0,0 +1,124 @@
+package yaml
+
+// PathHandler is like EventHandler, with the path of each node added to its
+// events; see TrackPaths. The nodes in a map key get the path of the map,
+// and its value the path of the entry, so a node whose path is that of the
+// map around it is (in) a key. As in Walk, an entry whose key isn't a
+// scalar, or an alias to one, is reached by its position in the map.
+type PathHandler interface {
+	DocumentStart(mark Mark)
+	DocumentEnd()
+
+	Null(path Path, mark Mark, anchor Anchor)
+	Alias(path Path, mark Mark, anchor Anchor)
+	Scalar(path Path, mark Mark, tag string, anchor Anchor, value string)
+
+	SequenceStart(path Path, mark Mark, tag string, anchor Anchor)
+	SequenceEnd(path Path)
+
+	MapStart(path Path, mark Mark, tag string, anchor Anchor)
+	MapEnd(path Path)
+}
+
+// BasePathHandler is a PathHandler that ignores every event, to embed like
+// BaseHandler.
+type BasePathHandler struct{}
+
+func (BasePathHandler) DocumentStart(mark Mark)                                              {}
+func (BasePathHandler) DocumentEnd()                                                         {}
+func (BasePathHandler) Null(path Path, mark Mark, anchor Anchor)                             {}
+func (BasePathHandler) Alias(path Path, mark Mark, anchor Anchor)                            {}
+func (BasePathHandler) Scalar(path Path, mark Mark, tag string, anchor Anchor, value string) {}
+func (BasePathHandler) SequenceStart(path Path, mark Mark, tag string, anchor Anchor)        {}
+func (BasePathHandler) SequenceEnd(path Path)                                                {}
+func (BasePathHandler) MapStart(path Path, mark Mark, tag string, anchor Anchor)             {}
+func (BasePathHandler) MapEnd(path Path)                                                     {}
+
+// TrackPaths returns an EventHandler that passes the events it receives on
+// to handler along with the path of their node, for streaming over a
+// document without building it:
+//
+//	type labels struct {
+//		yaml.BasePathHandler
+//		found map[string]string
+//	}
+//
+//	func (l *labels) Scalar(path yaml.Path, mark yaml.Mark, tag string, anchor yaml.Anchor, value string) {
+//		if len(path) == 3 && path[:2].String() == "metadata.labels" {
+//			l.found[path[2].Key] = value
+//		}
+//	}
+//
+// Anchor names and versions are passed on if handler implements
+// AnchorNameHandler or VersionHandler. Each event gets a path of its own,
+// which handler may keep.
+func TrackPaths(handler PathHandler) EventHandler {
+	return &pathHandler{handler: handler}
+}
+
+type pathHandler struct {
+	handler PathHandler
+	paths   pathTracker
+}
+
+func (p *pathHandler) AnchorName(mark Mark, anchor Anchor, name string) {
+	if h, ok := p.handler.(AnchorNameHandler); ok {
+		h.AnchorName(mark, anchor, name)
+	}
+}
+
+func (p *pathHandler) DocumentStart(mark Mark) {
+	p.paths.reset()
+	p.handler.DocumentStart(mark)
+}
+
+func (p *pathHandler) DocumentVersion(mark Mark, version Version) {
+	p.paths.version = version
+	passVersion(p.handler, mark, version)
+}
+
+func (p *pathHandler) DocumentEnd() {
+	p.handler.DocumentEnd()
+}
+
+func (p *pathHandler) Null(mark Mark, anchor Anchor) {
+	path, _ := p.paths.current()
+	p.paths.next("", false)
+	p.handler.Null(path, mark, anchor)
+}
+
+func (p *pathHandler) Alias(mark Mark, anchor Anchor) {
+	path, _ := p.paths.current()
+	p.paths.next(p.paths.alias(anchor))
+	p.handler.Alias(path, mark, anchor)
+}
+
+func (p *pathHandler) Scalar(mark Mark, tag string, anchor Anchor, value string) {
+	path, _ := p.paths.current()
+	p.paths.next(p.paths.scalar(anchor, tag, value))
+	p.handler.Scalar(path, mark, tag, anchor, value)
+}
+
+func (p *pathHandler) SequenceStart(mark Mark, tag string, anchor Anchor) {
+	path, _ := p.paths.current()
+	p.paths.start(false)
+	p.handler.SequenceStart(path, mark, tag, anchor)
+}
+
+func (p *pathHandler) SequenceEnd() {
+	path := p.paths.top().path
+	p.paths.end()
+	p.handler.SequenceEnd(path)
+}
+
+func (p *pathHandler) MapStart(mark Mark, tag string, anchor Anchor) {
+	path, _ := p.paths.current()
+	p.paths.start(true)
+	p.handler.MapStart(path, mark, tag, anchor)
+}
+
+func (p *pathHandler) MapEnd() {
+	path := p.paths.top().path
+	p.paths.end()
+	p.handler.MapEnd(path)
+}
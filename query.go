@@ -0,0 +1,662 @@
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled path expression in a JSONPath dialect, for picking
+// nodes out of a document:
+//
+//	spec.containers[*].image       every container's image
+//	$.items[0].metadata.name       the leading $ (the root) is optional
+//	metadata.labels["app/name"]    keys that aren't identifiers are quoted
+//	..image                        image values at any depth
+//	items[-1]                      the last item
+//	items[1:5:2], items[:3]        slices, as in Python
+//	items[0,2], spec["a","b"]      several selectors at once
+//	items[?(@.kind == "Pod")]      items matching a filter
+//	items[?@.replicas > 1 && !@.paused]
+//
+// A filter compares the first node a relative (@) or absolute ($) path
+// matches with another path or a literal: a string, a number, true, false
+// or null. Scalars are compared as the null, boolean, number or string
+// their core schema tag makes them (see ResolveTag), so 0x10 equals 16.
+// Comparisons combine with &&, || and !, and a path on its own tests whether
+// it matches anything.
+type Query struct {
+	expr     string
+	segments []querySegment
+}
+
+// Match is a node selected by a query, with its path from the root.
+type Match struct {
+	Path Path
+	Node *Node
+}
+
+// QueryError reports a malformed query expression.
+type QueryError struct {
+	Query  string
+	Offset int // into Query, in bytes
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query %q, offset %d - %s", e.Query, e.Offset, e.Msg)
+}
+
+// querySegment applies its selectors to each node it is given, or, when
+// recursive, to each of their descendants too.
+type querySegment struct {
+	recursive bool
+	selectors []querySelector
+}
+
+// querySelector appends what it selects from m to out.
+type querySelector func(m Match, root *Node, out []Match) []Match
+
+// queryFilter tests the current node of a filter.
+type queryFilter func(current *Node, root *Node) bool
+
+// queryOperand evaluates one side of a comparison; exists is false for a
+// path that matches nothing.
+type queryOperand func(current *Node, root *Node) (value queryValue, exists bool)
+
+func CompileQuery(expr string) (query *Query, err error) {
+	// Handle syntax panics.
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("yamlgo: %v", r)
+			}
+		}
+	}()
+
+	c := &queryCompiler{expr: expr}
+	c.skipSpace()
+	if c.peek() == '$' {
+		c.pos++
+	} else if c.pos < len(expr) && c.peek() != '.' && c.peek() != '[' {
+		// a bare first key, as in spec.containers
+		c.segments = append(c.segments, querySegment{selectors: []querySelector{c.parseDotted()}})
+	}
+	segments := c.parseSegments()
+
+	c.skipSpace()
+	if c.pos < len(expr) {
+		c.unexpected()
+	}
+	return &Query{expr: expr, segments: segments}, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the expression is
+// malformed.
+func MustCompileQuery(expr string) *Query {
+	query, err := CompileQuery(expr)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
+
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Find returns the nodes the query selects from root, in document order.
+func (q *Query) Find(root *Node) []Match {
+	return runQuery(q.segments, Match{Path: Path{}, Node: root}, root)
+}
+
+// Query compiles expr and runs it against n; see Query.
+func (n *Node) Query(expr string) ([]Match, error) {
+	query, err := CompileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return query.Find(n), nil
+}
+
+func runQuery(segments []querySegment, start Match, root *Node) []Match {
+	matches := []Match{start}
+	for _, segment := range segments {
+		var next []Match
+		for _, m := range matches {
+			if segment.recursive {
+				for _, d := range descendants(m, nil, make(map[*Node]bool)) {
+					for _, selector := range segment.selectors {
+						next = selector(d, root, next)
+					}
+				}
+			} else {
+				for _, selector := range segment.selectors {
+					next = selector(m, root, next)
+				}
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// descendants appends m and every node below it, visiting each node once so
+// that recursive trees end.
+func descendants(m Match, out []Match, visited map[*Node]bool) []Match {
+	if visited[m.Node] {
+		return out
+	}
+	visited[m.Node] = true
+
+	out = append(out, m)
+	for _, child := range children(m) {
+		out = descendants(child, out, visited)
+	}
+	return out
+}
+
+// children returns the items of a sequence or the values of a map.
+func children(m Match) []Match {
+	var out []Match
+	switch m.Node.Type {
+	case NODE_SEQUENCE:
+		for i, item := range m.Node.Children {
+			out = append(out, Match{m.Path.child(PathElement{Index: i, IsIndex: true}), item})
+		}
+	case NODE_MAP:
+		for i, pair := range m.Node.Pairs {
+			out = append(out, Match{m.Path.child(pairPathElement(i, pair)), pair.Value})
+		}
+	}
+	return out
+}
+
+func pairPathElement(i int, pair NodePair) PathElement {
+	if pair.Key.Type != NODE_SCALAR {
+		return PathElement{Index: i, IsEntry: true}
+	}
+	return PathElement{Key: pair.Key.Value, Index: i}
+}
+
+func wildcardSelector(m Match, root *Node, out []Match) []Match {
+	return append(out, children(m)...)
+}
+
+func keySelector(key string) querySelector {
+	return func(m Match, root *Node, out []Match) []Match {
+		if m.Node.Type != NODE_MAP {
+			return out
+		}
+		for i, pair := range m.Node.Pairs {
+			if pair.Key.Type == NODE_SCALAR && pair.Key.Value == key {
+				out = append(out, Match{m.Path.child(pairPathElement(i, pair)), pair.Value})
+			}
+		}
+		return out
+	}
+}
+
+func indexSelector(index int) querySelector {
+	return func(m Match, root *Node, out []Match) []Match {
+		i := index
+		if i < 0 {
+			i += len(m.Node.Children)
+		}
+		if item := m.Node.Index(i); item != nil {
+			out = append(out, Match{m.Path.child(PathElement{Index: i, IsIndex: true}), item})
+		}
+		return out
+	}
+}
+
+// sliceSelector selects items[start:end:step], where nil bounds default to
+// the ends of the sequence in the direction of step.
+func sliceSelector(start, end *int, step int) querySelector {
+	return func(m Match, root *Node, out []Match) []Match {
+		if m.Node.Type != NODE_SEQUENCE || step == 0 {
+			return out
+		}
+
+		l := len(m.Node.Children)
+		bound := func(b *int, def int) int {
+			if b == nil {
+				return def
+			} else if *b < 0 {
+				return max(*b+l, -1)
+			}
+			return min(*b, l)
+		}
+
+		if step > 0 {
+			for i := max(bound(start, 0), 0); i < bound(end, l); i += step {
+				out = indexSelector(i)(m, root, out)
+			}
+		} else {
+			for i := min(bound(start, l-1), l-1); i > bound(end, -1); i += step {
+				out = indexSelector(i)(m, root, out)
+			}
+		}
+		return out
+	}
+}
+
+func filterSelector(filter queryFilter) querySelector {
+	return func(m Match, root *Node, out []Match) []Match {
+		for _, child := range children(m) {
+			if filter(child.Node, root) {
+				out = append(out, child)
+			}
+		}
+		return out
+	}
+}
+
+type queryCompiler struct {
+	expr     string
+	pos      int
+	segments []querySegment
+}
+
+func (c *queryCompiler) fail(msg string) {
+	panic(&QueryError{c.expr, c.pos, msg})
+}
+
+// peek returns the current byte, or 0 at the end of the expression.
+func (c *queryCompiler) peek() byte {
+	if c.pos >= len(c.expr) {
+		return 0
+	}
+	return c.expr[c.pos]
+}
+
+func (c *queryCompiler) skipSpace() {
+	for c.peek() == ' ' || c.peek() == '\t' {
+		c.pos++
+	}
+}
+
+func (c *queryCompiler) consume(s string) bool {
+	c.skipSpace()
+	if strings.HasPrefix(c.expr[c.pos:], s) {
+		c.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// unexpected fails on the current character.
+func (c *queryCompiler) unexpected() {
+	if c.pos >= len(c.expr) {
+		c.fail(ERR_QUERY_UNEXPECTED_END)
+	}
+	c.fail(ERR_QUERY_UNEXPECTED + string(c.peek()))
+}
+
+func (c *queryCompiler) expect(s string) {
+	if !c.consume(s) {
+		c.unexpected()
+	}
+}
+
+// parseSegments reads the segments following a path's root. The segments
+// read so far are kept in c.segments, and the list is reset for nested
+// paths.
+func (c *queryCompiler) parseSegments() []querySegment {
+	segments := c.segments
+	c.segments = nil
+
+	for {
+		switch {
+		case strings.HasPrefix(c.expr[c.pos:], ".."):
+			c.pos += 2
+			segment := querySegment{recursive: true}
+			if c.peek() == '[' {
+				segment.selectors = c.parseBracket()
+			} else {
+				segment.selectors = []querySelector{c.parseDotted()}
+			}
+			segments = append(segments, segment)
+		case c.peek() == '.':
+			c.pos++
+			segments = append(segments, querySegment{selectors: []querySelector{c.parseDotted()}})
+		case c.peek() == '[':
+			segments = append(segments, querySegment{selectors: c.parseBracket()})
+		default:
+			return segments
+		}
+	}
+}
+
+// parseDotted reads the key or wildcard after a dot.
+func (c *queryCompiler) parseDotted() querySelector {
+	if c.peek() == '*' {
+		c.pos++
+		return wildcardSelector
+	}
+	return keySelector(c.name())
+}
+
+// name reads an unquoted key.
+func (c *queryCompiler) name() string {
+	start := c.pos
+	for c.pos < len(c.expr) && !strings.ContainsRune(".[]()=!<>&|,'\" \t", rune(c.expr[c.pos])) {
+		c.pos++
+	}
+	if c.pos == start {
+		c.unexpected()
+	}
+	return c.expr[start:c.pos]
+}
+
+func (c *queryCompiler) parseBracket() []querySelector {
+	c.expect("[")
+
+	var selectors []querySelector
+	for {
+		selectors = append(selectors, c.parseSelector())
+		if !c.consume(",") {
+			break
+		}
+	}
+
+	c.expect("]")
+	return selectors
+}
+
+func (c *queryCompiler) parseSelector() querySelector {
+	c.skipSpace()
+	switch ch := c.peek(); {
+	case ch == '*':
+		c.pos++
+		return wildcardSelector
+	case ch == '\'' || ch == '"':
+		return keySelector(c.quoted())
+	case ch == '?':
+		c.pos++
+		return filterSelector(c.parseOr())
+	}
+
+	// an index or a slice
+	var bounds [3]*int
+	n := 0
+	for ; n < 3; n++ {
+		c.skipSpace()
+		if ch := c.peek(); ch == '-' || ch >= '0' && ch <= '9' {
+			i := c.integer()
+			bounds[n] = &i
+		}
+		if !c.consume(":") {
+			break
+		}
+	}
+
+	switch n {
+	case 0:
+		if bounds[0] == nil {
+			c.unexpected()
+		}
+		return indexSelector(*bounds[0])
+	case 3:
+		c.fail(ERR_QUERY_UNEXPECTED + ":")
+	}
+
+	step := 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	return sliceSelector(bounds[0], bounds[1], step)
+}
+
+func (c *queryCompiler) integer() int {
+	start := c.pos
+	if c.peek() == '-' {
+		c.pos++
+	}
+	for c.peek() >= '0' && c.peek() <= '9' {
+		c.pos++
+	}
+
+	i, err := strconv.Atoi(c.expr[start:c.pos])
+	if err != nil {
+		c.pos = start
+		c.fail(ERR_QUERY_BAD_INDEX + c.expr[start:])
+	}
+	return i
+}
+
+// quoted reads a single- or double-quoted string, with backslash escapes.
+func (c *queryCompiler) quoted() string {
+	quote := c.expr[c.pos]
+	start := c.pos
+	c.pos++
+
+	var b strings.Builder
+	for {
+		if c.pos >= len(c.expr) {
+			c.pos = start
+			c.fail(ERR_QUERY_UNCLOSED_QUOTE)
+		}
+
+		ch := c.expr[c.pos]
+		c.pos++
+		switch {
+		case ch == quote:
+			return b.String()
+		case ch == '\\' && c.pos < len(c.expr):
+			b.WriteByte(c.expr[c.pos])
+			c.pos++
+		default:
+			b.WriteByte(ch)
+		}
+	}
+}
+
+func (c *queryCompiler) parseOr() queryFilter {
+	left := c.parseAnd()
+	for c.consume("||") {
+		a, b := left, c.parseAnd()
+		left = func(current, root *Node) bool {
+			return a(current, root) || b(current, root)
+		}
+	}
+	return left
+}
+
+func (c *queryCompiler) parseAnd() queryFilter {
+	left := c.parseUnary()
+	for c.consume("&&") {
+		a, b := left, c.parseUnary()
+		left = func(current, root *Node) bool {
+			return a(current, root) && b(current, root)
+		}
+	}
+	return left
+}
+
+func (c *queryCompiler) parseUnary() queryFilter {
+	if c.consume("!") {
+		f := c.parseUnary()
+		return func(current, root *Node) bool {
+			return !f(current, root)
+		}
+	} else if c.consume("(") {
+		f := c.parseOr()
+		c.expect(")")
+		return f
+	}
+	return c.parseComparison()
+}
+
+var queryComparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (c *queryCompiler) parseComparison() queryFilter {
+	start := c.pos
+	left, isPath := c.parseOperand()
+
+	op := ""
+	for _, o := range queryComparisons {
+		if c.consume(o) {
+			op = o
+			break
+		}
+	}
+
+	if len(op) == 0 {
+		if !isPath {
+			c.pos = start
+			c.fail(ERR_QUERY_COMPARISON)
+		}
+		return func(current, root *Node) bool {
+			_, exists := left(current, root)
+			return exists
+		}
+	}
+
+	right, _ := c.parseOperand()
+	return func(current, root *Node) bool {
+		a, aExists := left(current, root)
+		b, bExists := right(current, root)
+		if !aExists || !bExists {
+			return op == "!=" && aExists != bExists
+		}
+		return a.compare(op, b)
+	}
+}
+
+// parseOperand reads a path or a literal.
+func (c *queryCompiler) parseOperand() (operand queryOperand, isPath bool) {
+	c.skipSpace()
+	switch ch := c.peek(); {
+	case ch == '@' || ch == '$':
+		c.pos++
+		segments := c.parseSegments()
+		relative := ch == '@'
+		return func(current, root *Node) (queryValue, bool) {
+			start := root
+			if relative {
+				start = current
+			}
+			matches := runQuery(segments, Match{Node: start}, root)
+			if len(matches) == 0 {
+				return queryValue{}, false
+			}
+			return nodeQueryValue(matches[0].Node), true
+		}, true
+	case ch == '\'' || ch == '"':
+		value := queryValue{kind: queryString, str: c.quoted()}
+		return literalOperand(value), false
+	case ch == '-' || ch == '+' || ch == '.' || ch >= '0' && ch <= '9':
+		start := c.pos
+		for c.pos < len(c.expr) && !strings.ContainsRune("[]()=!<>&|,'\" \t", rune(c.expr[c.pos])) {
+			c.pos++
+		}
+		value := scalarQueryValue("?", c.expr[start:c.pos])
+		if value.kind != queryNumber {
+			c.pos = start
+			c.unexpected()
+		}
+		return literalOperand(value), false
+	}
+
+	switch word := c.name(); word {
+	case "true", "false", "null":
+		return literalOperand(scalarQueryValue("?", word)), false
+	default:
+		c.pos -= len(word)
+		c.unexpected()
+	}
+	return
+}
+
+func literalOperand(value queryValue) queryOperand {
+	return func(current, root *Node) (queryValue, bool) {
+		return value, true
+	}
+}
+
+type queryValueKind int
+
+const (
+	queryNull queryValueKind = iota
+	queryBool
+	queryNumber
+	queryString
+	queryCollection
+)
+
+type queryValue struct {
+	kind queryValueKind
+	str  string
+	num  float64
+	node *Node // of a collection
+}
+
+func nodeQueryValue(node *Node) queryValue {
+	switch node.Type {
+	case NODE_SCALAR:
+		return scalarQueryValue(node.Tag, node.Value)
+	case NODE_SEQUENCE, NODE_MAP:
+		return queryValue{kind: queryCollection, node: node}
+	}
+	return queryValue{kind: queryNull}
+}
+
+// scalarQueryValue reads a scalar as the null, boolean, number or string its
+// core schema tag makes it.
+func scalarQueryValue(tag string, value string) queryValue {
+	switch resolved := ResolveTag(tag, value); resolved {
+	case TAG_NULL:
+		return queryValue{kind: queryNull}
+	case TAG_BOOL:
+		return queryValue{kind: queryBool, str: strings.ToLower(value)}
+	case TAG_INT, TAG_FLOAT:
+		if num, err := strconv.ParseFloat(normalizeScalar(resolved, value), 64); err == nil {
+			return queryValue{kind: queryNumber, str: value, num: num}
+		}
+	}
+	return queryValue{kind: queryString, str: value}
+}
+
+func (a queryValue) compare(op string, b queryValue) bool {
+	if a.kind != b.kind {
+		return op == "!="
+	}
+
+	cmp := 0
+	switch a.kind {
+	case queryNumber:
+		if a.num < b.num {
+			cmp = -1
+		} else if a.num > b.num {
+			cmp = 1
+		}
+	case queryString:
+		cmp = strings.Compare(a.str, b.str)
+	case queryCollection:
+		if a.node != b.node {
+			return op == "!="
+		}
+	case queryBool:
+		if a.str != b.str {
+			return op == "!="
+		}
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0 && (a.kind == queryNumber || a.kind == queryString)
+	case "<=":
+		return cmp <= 0 && (a.kind == queryNumber || a.kind == queryString)
+	case ">":
+		return cmp > 0 && (a.kind == queryNumber || a.kind == queryString)
+	case ">=":
+		return cmp >= 0 && (a.kind == queryNumber || a.kind == queryString)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package yaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustLoad(t *testing.T, text string) *Node {
+	t.Helper()
+	doc, err := Load(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("loading %q: %v", text, err)
+	}
+	return doc
+}
+
+const queryDoc = `items:
+  - name: a
+    replicas: 0x10
+    kind: Pod
+  - name: b
+    replicas: 3
+    paused: true
+    kind: Pod
+  - name: c
+    replicas: .inf
+    kind: Service
+  - name: d
+    replicas: "16"
+    kind: Pod
+spec:
+  image: outer
+  containers:
+    - image: first
+    - image: second
+`
+
+func TestQuery(t *testing.T) {
+	doc := mustLoad(t, queryDoc)
+
+	tests := []struct {
+		query string
+		paths []string
+	}{
+		{"items[0].name", []string{"items[0].name"}},
+		{"$.items[-1].name", []string{"items[3].name"}},
+		{"items[1:3]", []string{"items[1]", "items[2]"}},
+		{"items[::2]", []string{"items[0]", "items[2]"}},
+		{"items[:-3]", []string{"items[0]"}},
+		{"items[::-1]", []string{"items[3]", "items[2]", "items[1]", "items[0]"}},
+		{"items[0,2].name", []string{"items[0].name", "items[2].name"}},
+		{`items[?(@.kind == "Service")].name`, []string{"items[2].name"}},
+		{"items[?@.replicas > 4 && !@.paused].name", []string{"items[0].name", "items[2].name"}},
+		{"items[?@.replicas == 16].name", []string{"items[0].name"}},
+		{"items[?@.replicas == 0x10].name", []string{"items[0].name"}},
+		{`items[?@.replicas == "16"].name`, []string{"items[3].name"}},
+		{"items[?@.replicas == .inf].name", []string{"items[2].name"}},
+		{"items[?@.paused].name", []string{"items[1].name"}},
+		{"items[?@.paused == true || @.replicas < 0].name", []string{"items[1].name"}},
+		{"items[?@.missing == null].name", nil},
+		{"..image", []string{"spec.image", "spec.containers[0].image", "spec.containers[1].image"}},
+		{"spec..image", []string{"spec.image", "spec.containers[0].image", "spec.containers[1].image"}},
+		{"..containers[*].image", []string{"spec.containers[0].image", "spec.containers[1].image"}},
+	}
+
+	for _, test := range tests {
+		query, err := CompileQuery(test.query)
+		if err != nil {
+			t.Errorf("%v: %v", test.query, err)
+			continue
+		}
+
+		var paths []string
+		for _, match := range query.Find(doc) {
+			paths = append(paths, match.Path.String())
+		}
+		if strings.Join(paths, " ") != strings.Join(test.paths, " ") {
+			t.Errorf("%v: expected %q, got %q", test.query, test.paths, paths)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, expr := range []string{"items[", "items[?@.a ==]", "items[?1]", "items[?@.a == 0z]", `items["a]`} {
+		if _, err := CompileQuery(expr); err == nil {
+			t.Errorf("%v: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package yaml
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// The tags of the YAML core schema.
+const (
+	TAG_NULL  = "tag:yaml.org,2002:null"
+	TAG_BOOL  = "tag:yaml.org,2002:bool"
+	TAG_INT   = "tag:yaml.org,2002:int"
+	TAG_FLOAT = "tag:yaml.org,2002:float"
+	TAG_STR   = "tag:yaml.org,2002:str"
+	TAG_SEQ   = "tag:yaml.org,2002:seq"
+	TAG_MAP   = "tag:yaml.org,2002:map"
+)
+
+var (
+	coreNullExp  = regexp.MustCompile(`^(~|null|Null|NULL|)$`)
+	coreBoolExp  = regexp.MustCompile(`^(true|True|TRUE|false|False|FALSE)$`)
+	coreIntExp   = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	coreFloatExp = regexp.MustCompile(`^([-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?|[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+
+	yaml11BoolExp  = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF)$`)
+	yaml11OctalExp = regexp.MustCompile(`^[-+]?0[0-7]+$`)
+)
+
+// ResolveTag returns the tag of a scalar with the given tag and value under
+// the YAML 1.2 core schema. The non-specific "?" tag of plain scalars
+// resolves by the value, "!" (quoted and block scalars) to TAG_STR, and any
+// other tag to itself.
+func ResolveTag(tag string, value string) string {
+	switch tag {
+	case "?", "":
+	case "!":
+		return TAG_STR
+	default:
+		return tag
+	}
+
+	switch {
+	case coreNullExp.MatchString(value):
+		return TAG_NULL
+	case coreBoolExp.MatchString(value):
+		return TAG_BOOL
+	case coreIntExp.MatchString(value):
+		return TAG_INT
+	case coreFloatExp.MatchString(value):
+		return TAG_FLOAT
+	}
+	return TAG_STR
+}
+
+// ResolveTagVersion is ResolveTag for a scalar of a document of the given
+// YAML version. Before 1.2, y, yes, on, n, no and off (capitalized or in
+// upper case too) are booleans as well, integers with a leading 0 are octal,
+// and 0o isn't an octal prefix.
+func ResolveTagVersion(tag string, value string, version Version) string {
+	if (tag == "?" || tag == "") && version.before12() {
+		switch {
+		case yaml11BoolExp.MatchString(value):
+			return TAG_BOOL
+		case yaml11OctalExp.MatchString(value):
+			return TAG_INT
+		case strings.HasPrefix(value, "0o"):
+			return TAG_STR
+		}
+	}
+	return ResolveTag(tag, value)
+}
+
+// coreScalar rewrites a plain scalar of a document of the given version to
+// one meaning the same under the YAML 1.2 core schema, which Node trees and
+// the output are in: the YAML 1.1 yes becomes true, 0755 becomes 0o755, and
+// 0o755 the string it is in YAML 1.1. Other scalars are returned as they are.
+func coreScalar(tag string, value string, version Version) (string, string) {
+	if tag != "?" && tag != "" || !version.before12() {
+		return tag, value
+	}
+
+	switch {
+	case yaml11BoolExp.MatchString(value):
+		if coreBoolExp.MatchString(value) {
+			return tag, value
+		}
+		switch strings.ToLower(value) {
+		case "y", "yes", "on":
+			return tag, "true"
+		}
+		return tag, "false"
+	case yaml11OctalExp.MatchString(value):
+		if value[0] != '-' {
+			return tag, "0o" + strings.TrimPrefix(value, "+")[1:]
+		}
+		// the core schema has no negative octal
+		i, _ := new(big.Int).SetString(value, 8)
+		return tag, i.String()
+	case strings.HasPrefix(value, "0o"):
+		return "!", value
+	}
+	return tag, value
+}
+
+// ResolvedTag returns the tag of the node under the core schema; see
+// ResolveTag.
+func (n *Node) ResolvedTag() string {
+	switch n.Type {
+	case NODE_NULL:
+		return TAG_NULL
+	case NODE_SCALAR:
+		return ResolveTag(n.Tag, n.Value)
+	case NODE_SEQUENCE:
+		if n.Tag == "?" || n.Tag == "!" || len(n.Tag) == 0 {
+			return TAG_SEQ
+		}
+	case NODE_MAP:
+		if n.Tag == "?" || n.Tag == "!" || len(n.Tag) == 0 {
+			return TAG_MAP
+		}
+	}
+	return n.Tag
+}
@@ -1,6 +1,7 @@
 package yaml
 
 import (
+	"context"
 	"io"
 )
 
@@ -26,128 +27,641 @@ const (
 )
 
 type Scanner struct {
-	reader io.Reader
+	input *stream
+
+	// the output (tokens), and the popped tokens kept for reuse. tokens
+	// slides along the array queue starts
+	tokens []*Token
+	queue  []*Token
+	free   []*Token
+
+	// state info
+	startedStream    bool
+	endedStream      bool
+	simpleKeyAllowed bool
+	canBeJSONFlow    bool
+	simpleKeys       []simpleKey
+	indents          []*indentMarker
+	flows            []flowMarker
+
+	// ctx cancels scanning and parsing when set; see checkContext
+	ctx    context.Context
+	checks int
 }
 
 type indentMarker struct {
+	column     int
+	itype      indentType
+	status     indentStatus
+	startToken *Token
 }
 
 func NewScanner(reader io.Reader) *Scanner {
-	return &Scanner{reader: reader}
+	return NewScannerSize(reader, DefaultMaxLookahead)
 }
 
+// NewScannerSize returns a scanner that keeps scalars of up to maxLookahead
+// bytes contiguous in its read buffer. Longer scalars are copied out, so
+// that no more than maxLookahead bytes of a scalar are carried over into
+// each new chunk of the buffer.
+//
+// This doesn't bound the scanner's memory. Scalars are sliced out of the
+// chunks they were read into, and a chunk is kept for as long as any value
+// sliced from it is, such as the scalars of a Node tree built from the
+// input.
+func NewScannerSize(reader io.Reader, maxLookahead int) *Scanner {
+	return &Scanner{input: newStream(reader, maxLookahead)}
+}
+
+// Empty returns true if there are no more tokens to be read.
 func (s *Scanner) Empty() bool {
-	return false
+	s.ensureTokensInQueue()
+	return len(s.tokens) == 0
 }
 
+// Peek returns the next token. The scanner must not be empty.
 func (s *Scanner) Peek() *Token {
-	return NewToken(TOKEN_VALUE, NullMark)
+	s.ensureTokensInQueue()
+	return s.tokens[0]
 }
 
+// Pop removes the next token from the queue. The token is reused for a
+// later one, so copy it to keep it.
 func (s *Scanner) Pop() {
-
+	s.ensureTokensInQueue()
+	if len(s.tokens) > 0 {
+		s.dequeue()
+	}
 }
 
+// Mark returns the current position in the input.
 func (s *Scanner) Mark() Mark {
-	return NullMark
+	return s.input.mark
 }
 
 /********************/
 /***** Scanning *****/
 /********************/
 
+// ensureTokensInQueue scans until there's a valid token at the front of the
+// queue, or we're sure the queue is empty.
 func (s *Scanner) ensureTokensInQueue() {
+	for {
+		if len(s.tokens) > 0 {
+			token := s.tokens[0]
+
+			// if this guy's valid, then we're done
+			if token.Status == VALID {
+				return
+			}
+
+			// here's where we clean up the impossible tokens
+			if token.Status == INVALID {
+				s.dequeue()
+				continue
+			}
+
+			// note: what's left are the unverified tokens
+		}
+
+		// no token? maybe we've actually finished
+		if s.endedStream {
+			return
+		}
+
+		// no? then scan...
+		s.checkContext()
+		s.scanNextToken()
+	}
 }
 
+// scanNextToken is the main scanning function; here we branch out and
+// scan whatever the next token should be.
 func (s *Scanner) scanNextToken() {
+	if s.endedStream {
+		return
+	}
+
+	if !s.startedStream {
+		s.startStream()
+		return
+	}
+
+	// get rid of whitespace, etc. (in between tokens it should be irrelevent)
+	s.scanToNextToken()
+
+	// maybe need to end some blocks
+	s.popIndentToHere()
+
+	// *****
+	// And now branch based on the next few characters!
+	// *****
+
+	in := s.input
+
+	// end of stream
+	if !in.ok() {
+		s.endStream()
+		return
+	}
+
+	if in.mark.Column == 0 && in.peek() == key_DIRECTIVE {
+		s.scanDirective()
+		return
+	}
+
+	// document token
+	if in.mark.Column == 0 && expDocStart(in) {
+		s.scanDocStart()
+		return
+	}
+
+	if in.mark.Column == 0 && expDocEnd(in) {
+		s.scanDocEnd()
+		return
+	}
+
+	// flow start/end/entry
+	if ch := in.peek(); ch == key_FLOW_SEQ_START || ch == key_FLOW_MAP_START {
+		s.scanFlowStart()
+		return
+	} else if ch == key_FLOW_SEQ_END || ch == key_FLOW_MAP_END {
+		s.scanFlowEnd()
+		return
+	} else if ch == key_FLOW_ENTRY {
+		s.scanFlowEntry()
+		return
+	}
+
+	// block/map stuff
+	if expBlockEntry(in) {
+		s.scanBlockEntry()
+		return
+	}
+
+	if expKey(in) {
+		s.scanKey()
+		return
+	}
+
+	if s.valueMatches() {
+		s.scanValue()
+		return
+	}
+
+	// alias/anchor
+	if ch := in.peek(); ch == key_ALIAS || ch == key_ANCHOR {
+		s.scanAnchorOrAlias()
+		return
+	}
+
+	// tag
+	if in.peek() == key_TAG {
+		s.scanTag()
+		return
+	}
+
+	// special scalars
+	if ch := in.peek(); s.inBlockContext() && (ch == key_LITERAL_SCALAR || ch == key_FOLDED_SCALAR) {
+		s.scanBlockScalar()
+		return
+	}
+
+	if ch := in.peek(); ch == '\'' || ch == '"' {
+		s.scanQuotedScalar()
+		return
+	}
+
+	// plain scalars
+	if (s.inBlockContext() && expPlainScalar(in)) || (s.inFlowContext() && expPlainScalarInFlow(in)) {
+		s.scanPlainScalar()
+		return
+	}
+
+	// don't know what it is!
+	panic(&ParseError{in.mark, ERR_UNKNOWN_TOKEN})
 }
 
+// scanToNextToken eats input until we reach the next token-like thing.
 func (s *Scanner) scanToNextToken() {
+	in := s.input
+	for {
+		// first eat whitespace
+		for in.ok() && s.isWhitespaceToBeEaten(in.peek()) {
+			if s.inBlockContext() && in.peek() == '\t' {
+				s.simpleKeyAllowed = false
+			}
+			in.eat(1)
+		}
+
+		// then eat a comment
+		if expComment(in) {
+			// eat until line break
+			for in.ok() && expBreak(in, 0) == 0 {
+				in.eat(1)
+			}
+		}
+
+		// if it's NOT a line break, then we're done!
+		n := expBreak(in, 0)
+		if n == 0 {
+			break
+		}
+
+		// otherwise, let's eat the line break and keep going
+		in.eat(n)
+
+		// oh yeah, and let's get rid of that simple key
+		s.invalidateSimpleKey()
+
+		// new line - we may be able to accept a simple key now
+		if s.inBlockContext() {
+			s.simpleKeyAllowed = true
+		}
+	}
 }
 
 func (s *Scanner) startStream() {
+	s.startedStream = true
+	s.simpleKeyAllowed = true
+	s.indents = append(s.indents, &indentMarker{column: -1, itype: it_NONE})
 }
 
 func (s *Scanner) endStream() {
+	// force newline
+	if s.input.mark.Column > 0 {
+		s.input.mark.Column = 0
+	}
+
+	s.popAllIndents()
+	s.popAllSimpleKeys()
+
+	s.simpleKeyAllowed = false
+	s.endedStream = true
 }
 
 func (s *Scanner) pushToken(ttype TokenType) *Token {
-	return nil
+	token := s.newToken(ttype, s.input.mark)
+	s.enqueue(token)
+	return token
+}
+
+// newToken is NewToken, reusing a popped token if there is one.
+func (s *Scanner) newToken(ttype TokenType, mark Mark) *Token {
+	if len(s.free) == 0 {
+		return &Token{Status: VALID, Type: ttype, Mark: mark, EndMark: mark}
+	}
+
+	token := s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+	*token = Token{Status: VALID, Type: ttype, Mark: mark, EndMark: mark, Params: token.Params[:0]}
+	return token
+}
+
+// enqueue adds a token to the back of the queue. Once the queue reaches the
+// end of its array, it moves back to the start if at least half of the
+// array is free, and grows otherwise, so each token is moved a constant
+// number of times on average however long the queue gets.
+func (s *Scanner) enqueue(token *Token) {
+	if len(s.tokens) == cap(s.tokens) {
+		array := s.queue[:cap(s.queue)]
+		if popped := len(array) - cap(s.tokens); popped > 0 && popped >= len(s.tokens) {
+			n := copy(array, s.tokens)
+			clear(array[n:])
+			s.tokens = array[:n]
+		} else {
+			s.tokens = append(s.tokens, token)
+			s.queue = s.tokens[:0]
+			return
+		}
+	}
+	s.tokens = append(s.tokens, token)
+}
+
+// dequeue removes the token at the front of the queue and keeps it for
+// reuse.
+func (s *Scanner) dequeue() {
+	s.free = append(s.free, s.tokens[0])
+	s.tokens[0] = nil
+	s.tokens = s.tokens[1:]
+}
+
+// reset makes the scanner read from reader, keeping its token pool and the
+// arrays of its stacks. The read buffer is not reused, since the values of
+// earlier tokens may still point into it.
+func (s *Scanner) reset(reader io.Reader) {
+	s.free = append(s.free, s.tokens...)
+	clear(s.tokens)
+	clear(s.indents)
+	clear(s.simpleKeys)
+
+	*s = Scanner{
+		input:      newStream(reader, s.input.maxLookahead),
+		tokens:     s.queue[:0],
+		queue:      s.queue[:0],
+		free:       s.free,
+		simpleKeys: s.simpleKeys[:0],
+		indents:    s.indents[:0],
+		flows:      s.flows[:0],
+	}
+}
+
+// setVersion makes the scanner read what follows as the given YAML version.
+// Tokens already queued were scanned under the previous one, so it is called
+// before the parser looks past the directives.
+func (s *Scanner) setVersion(version Version) {
+	s.input.yaml11 = version.before12()
 }
 
 func (s *Scanner) inFlowContext() bool {
-	//return !s.flows.empty()
-	return true
+	return len(s.flows) > 0
 }
 
 func (s *Scanner) inBlockContext() bool {
-	//return s.flows.empty()
-	return true
+	return len(s.flows) == 0
 }
 
 func (s *Scanner) getFlowLevel() int {
-	//return s.flows.size()
-	return 0
+	return len(s.flows)
+}
+
+// valueMatches checks for a ':' value indicator, whose rules depend on the
+// context.
+func (s *Scanner) valueMatches() bool {
+	if s.inBlockContext() {
+		return expValue(s.input)
+	} else if s.canBeJSONFlow {
+		return expValueInJSONFlow(s.input)
+	}
+	return expValueInFlow(s.input)
 }
 
 func (s *Scanner) getStartTokenFor(itype indentType) TokenType {
-	return TOKEN_DIRECTIVE
+	switch itype {
+	case it_SEQ:
+		return TOKEN_BLOCK_SEQ_START
+	case it_MAP:
+		return TOKEN_BLOCK_MAP_START
+	}
+	panic(&ParseError{s.input.mark, "invalid indent type"})
 }
 
+// pushIndentTo pushes an indentation onto the stack, and enqueues the proper
+// token (sequence start or mapping start). It returns the indent marker it
+// generates (if any).
 func (s *Scanner) pushIndentTo(column int, itype indentType) *indentMarker {
-	return nil
+	// are we in flow?
+	if s.inFlowContext() {
+		return nil
+	}
+
+	lastIndent := s.indents[len(s.indents)-1]
+
+	// is this actually an indentation?
+	if column < lastIndent.column {
+		return nil
+	}
+	if column == lastIndent.column && !(itype == it_SEQ && lastIndent.itype == it_MAP) {
+		return nil
+	}
+
+	// push a start token
+	indent := &indentMarker{column: column, itype: itype}
+	indent.startToken = s.pushToken(s.getStartTokenFor(itype))
+
+	// and then the indent
+	s.indents = append(s.indents, indent)
+	return indent
 }
 
+// popIndentToHere pops indentations off the stack until we reach the current
+// indentation level, and enqueues the proper token each time. Then pops all
+// invalid indentations off.
 func (s *Scanner) popIndentToHere() {
+	// are we in flow?
+	if s.inFlowContext() {
+		return
+	}
+
+	// now pop away
+	for len(s.indents) > 0 {
+		indent := s.indents[len(s.indents)-1]
+		if indent.column < s.input.mark.Column {
+			break
+		}
+		if indent.column == s.input.mark.Column && !(indent.itype == it_SEQ && !expBlockEntry(s.input)) {
+			break
+		}
+
+		s.popIndent()
+	}
+
+	for len(s.indents) > 0 && s.indents[len(s.indents)-1].status == is_INVALID {
+		s.popIndent()
+	}
 }
 
+// popAllIndents pops all indentations (except for the base empty one) off the
+// stack, and enqueues the proper token each time.
 func (s *Scanner) popAllIndents() {
+	// are we in flow?
+	if s.inFlowContext() {
+		return
+	}
+
+	// now pop away
+	for len(s.indents) > 0 {
+		if s.indents[len(s.indents)-1].itype == it_NONE {
+			break
+		}
+		s.popIndent()
+	}
 }
 
+// popIndent pops a single indent, pushing the proper token.
 func (s *Scanner) popIndent() {
+	indent := s.indents[len(s.indents)-1]
+	s.indents = s.indents[:len(s.indents)-1]
+
+	if indent.status != is_VALID {
+		s.invalidateSimpleKey()
+		return
+	}
+
+	switch indent.itype {
+	case it_SEQ:
+		s.pushToken(TOKEN_BLOCK_SEQ_END)
+	case it_MAP:
+		s.pushToken(TOKEN_BLOCK_MAP_END)
+	}
 }
 
 func (s *Scanner) getTopIndent() int {
-	return 0
+	if len(s.indents) == 0 {
+		return 0
+	}
+	return s.indents[len(s.indents)-1].column
 }
 
 /**************************/
 /***** Checking Input *****/
 /**************************/
 
+type simpleKey struct {
+	mark      Mark
+	flowLevel int
+	indent    *indentMarker
+	mapStart  *Token
+	key       *Token
+}
+
+func (k *simpleKey) validate() {
+	// Note: pIndent will *not* be garbage here;
+	//       we "garbage collect" them so we can
+	//       always refer to them
+	if k.indent != nil {
+		k.indent.status = is_VALID
+	}
+	if k.mapStart != nil {
+		k.mapStart.Status = VALID
+	}
+	if k.key != nil {
+		k.key.Status = VALID
+	}
+}
+
+func (k *simpleKey) invalidate() {
+	if k.indent != nil {
+		k.indent.status = is_INVALID
+	}
+	if k.mapStart != nil {
+		k.mapStart.Status = INVALID
+	}
+	if k.key != nil {
+		k.key.Status = INVALID
+	}
+}
+
 func (s *Scanner) canInsertPotentialSimpleKey() bool {
-	return false
+	if !s.simpleKeyAllowed {
+		return false
+	}
+	return !s.existsActiveSimpleKey()
 }
 
+// existsActiveSimpleKey returns true if there's a potential simple key at
+// our flow level (there's allowed at most one per flow level, i.e., at the
+// start of the flow start token).
 func (s *Scanner) existsActiveSimpleKey() bool {
-	return false
+	if len(s.simpleKeys) == 0 {
+		return false
+	}
+	return s.simpleKeys[len(s.simpleKeys)-1].flowLevel == s.getFlowLevel()
 }
 
+// insertPotentialSimpleKey adds a potential simple key to the queue, and
+// saves it on a stack.
 func (s *Scanner) insertPotentialSimpleKey() {
+	if !s.canInsertPotentialSimpleKey() {
+		return
+	}
+
+	key := simpleKey{mark: s.input.mark, flowLevel: s.getFlowLevel()}
+
+	// first add a map start, if necessary
+	if s.inBlockContext() {
+		key.indent = s.pushIndentTo(s.input.mark.Column, it_MAP)
+		if key.indent != nil {
+			key.indent.status = is_UNKNOWN
+			key.mapStart = key.indent.startToken
+			key.mapStart.Status = UNVERIFIED
+		}
+	}
+
+	// then add the (now unverified) key
+	key.key = s.pushToken(TOKEN_KEY)
+	key.key.Status = UNVERIFIED
+
+	s.simpleKeys = append(s.simpleKeys, key)
 }
 
+// invalidateSimpleKey invalidates the top potential simple key (if it
+// exists) at the current flow level.
 func (s *Scanner) invalidateSimpleKey() {
+	if len(s.simpleKeys) == 0 {
+		return
+	}
+
+	// grab top key
+	key := &s.simpleKeys[len(s.simpleKeys)-1]
+	if key.flowLevel != s.getFlowLevel() {
+		return
+	}
+
+	key.invalidate()
+	s.simpleKeys = s.simpleKeys[:len(s.simpleKeys)-1]
 }
 
+// verifySimpleKey determines whether the latest simple key to be added is
+// valid, and if so, makes it valid.
 func (s *Scanner) verifySimpleKey() bool {
-	return true
+	if len(s.simpleKeys) == 0 {
+		return false
+	}
+
+	// grab top key
+	key := s.simpleKeys[len(s.simpleKeys)-1]
+
+	// only validate if we're in the correct flow level
+	if key.flowLevel != s.getFlowLevel() {
+		return false
+	}
+
+	s.simpleKeys = s.simpleKeys[:len(s.simpleKeys)-1]
+
+	isValid := true
+
+	// needs to be less than 1024 characters and inline
+	if s.input.mark.Line != key.mark.Line || s.input.mark.Pos-key.mark.Pos > maxSimpleKeyLength {
+		isValid = false
+	}
+
+	// invalidate key
+	if isValid {
+		key.validate()
+	} else {
+		key.invalidate()
+	}
+
+	return isValid
 }
 
 func (s *Scanner) popAllSimpleKeys() {
+	s.simpleKeys = s.simpleKeys[:0]
 }
 
+// maxSimpleKeyLength is how far a simple key may start before its colon.
+const maxSimpleKeyLength = 1024
 
-func (s *Scanner) panicParserException(msg string) {
-	/*
-	mark := NullMark
-	if(!m_tokens.empty()) {
-		const Token& token = m_tokens.front()
-		mark = token.mark
+// contextCheckInterval is how many tokens and nodes go by between looks at
+// the context, which takes a lock.
+const contextCheckInterval = 256
+
+// checkContext panics with a *CanceledError if the scanner's context is
+// done. It only looks every contextCheckInterval calls.
+func (s *Scanner) checkContext() {
+	if s.ctx == nil {
+		return
+	}
+	s.checks++
+	if s.checks%contextCheckInterval != 0 {
+		return
+	}
+	if err := s.ctx.Err(); err != nil {
+		panic(&CanceledError{s.Mark(), err})
 	}
-	throw ParserException(mark, msg)
-	*/
 }
 
-func (s *Scanner) isWhitespaceToBeEaten(ch byte) bool {
-	return true
+func (s *Scanner) isWhitespaceToBeEaten(ch int) bool {
+	return ch == ' ' || ch == '\t'
 }
@@ -0,0 +1,360 @@
+package yaml
+
+import (
+	"unsafe"
+)
+
+type chompType int
+type foldType int
+type actionType int
+
+const (
+	chomp_STRIP chompType = iota
+	chomp_CLIP
+	chomp_KEEP
+)
+
+const (
+	fold_DONT foldType = iota
+	fold_BLOCK
+	fold_FLOW
+)
+
+const (
+	action_NONE actionType = iota
+	action_BREAK
+	action_THROW
+)
+
+type scanScalarParams struct {
+	// input control
+	end                  func(in *stream) bool // what condition ends this scalar?
+	endLength            int                   // how much of the end to eat
+	eatEnd               bool                  // should we eat that condition when we see it?
+	indent               int                   // what level of indentation should be eaten and ignored?
+	detectIndent         bool                  // should we try to autodetect the indent?
+	eatLeadingWhitespace bool                  // should we continue eating this delicious indentation after 'indent' spaces?
+	escape               int                   // what character do we escape on (i.e., slash or single quote) (-1 for none)
+	fold                 foldType              // how do we fold line ends?
+	trimTrailingSpaces   bool                  // do we remove all trailing spaces (at the very end)
+	chomp                chompType             // do we strip, clip, or keep trailing newlines (at the very end)
+	//   Note: strip means kill all, clip means keep at most one, keep means keep all
+	onDocIndicator     actionType // what do we do if we see a document indicator?
+	onTabInIndentation actionType // what do we do if we see a tab where we should be seeing indentation spaces
+
+	// output
+	leadingSpaces bool
+	endMark       Mark // just past the last character that belongs to the scalar
+}
+
+func newScanScalarParams() scanScalarParams {
+	return scanScalarParams{end: expEOF, escape: -1}
+}
+
+// scalarBuilder accumulates a scalar value. As long as the value is a
+// verbatim run of the input it only records the span; anything else (an
+// escape, a folded line break, dropped indentation) switches it to an owned
+// copy.
+type scalarBuilder struct {
+	in    *stream
+	start int // absolute position of the verbatim span
+	n     int
+	owned []byte
+	copy  bool
+}
+
+func (b *scalarBuilder) size() int {
+	if b.copy {
+		return len(b.owned)
+	}
+	return b.n
+}
+
+func (b *scalarBuilder) bytes() []byte {
+	if b.copy {
+		return b.owned
+	}
+	if b.n == 0 {
+		return nil
+	}
+	return b.in.slice(b.start, b.start+b.n)
+}
+
+// spill switches the builder to an owned copy of its value.
+func (b *scalarBuilder) spill() {
+	if b.copy {
+		return
+	}
+	b.owned = append(b.owned[:0], b.bytes()...)
+	b.copy = true
+	if b.in.pinned == b {
+		b.in.pinned = nil
+	}
+}
+
+// consume moves the next input byte onto the end of the value.
+func (b *scalarBuilder) consume() {
+	if !b.copy {
+		pos := b.in.mark.Pos
+		if b.n == 0 {
+			b.start = pos
+			b.in.pinned = b
+		}
+		if b.start+b.n == pos {
+			b.in.get()
+			b.n++
+			return
+		}
+		b.spill()
+	}
+	b.owned = append(b.owned, b.in.get())
+}
+
+func (b *scalarBuilder) appendString(str string) {
+	b.spill()
+	b.owned = append(b.owned, str...)
+}
+
+func (b *scalarBuilder) truncate(n int) {
+	if n >= b.size() {
+		return
+	}
+	if b.copy {
+		b.owned = b.owned[:n]
+	} else {
+		b.n = n
+	}
+}
+
+// lastNot returns the index of the last byte that isn't ch, or -1.
+func (b *scalarBuilder) lastNot(ch byte) int {
+	value := b.bytes()
+	for i := len(value) - 1; i >= 0; i-- {
+		if value[i] != ch {
+			return i
+		}
+	}
+	return -1
+}
+
+// String returns the value, sharing the input buffer when it is verbatim.
+func (b *scalarBuilder) String() string {
+	if b.in.pinned == b {
+		b.in.pinned = nil
+	}
+	if value := b.bytes(); b.copy {
+		return string(value)
+	} else if len(value) > 0 {
+		return unsafe.String(&value[0], len(value))
+	}
+	return ""
+}
+
+// scanScalar is the workhorse behind plain, quoted and block scalars.
+// The behaviour is controlled by params, see scanScalarParams.
+func scanScalar(in *stream, params *scanScalarParams) string {
+	foundNonEmptyLine := false
+	pastOpeningBreak := params.fold == fold_FLOW
+	emptyLine, moreIndented := false, false
+	foldedNewlineCount := 0
+	foldedNewlineStartedMoreIndented := false
+	lastEscapedChar := -1
+	scalar := in.scalarBuilder()
+	params.leadingSpaces = false
+	params.endMark = in.mark
+
+	for {
+		// ********************************
+		// Phase #1: scan until line ending
+
+		lastNonWhitespaceChar := scalar.size()
+		escapedNewline := false
+		for !params.end(in) && expBreak(in, 0) == 0 {
+			if !in.ok() {
+				break
+			}
+
+			// document indicator?
+			if in.mark.Column == 0 && (expDocStart(in) || expDocEnd(in)) {
+				if params.onDocIndicator == action_BREAK {
+					break
+				} else if params.onDocIndicator == action_THROW {
+					panic(&ParseError{in.mark, ERR_DOC_IN_SCALAR})
+				}
+			}
+
+			foundNonEmptyLine = true
+			pastOpeningBreak = true
+
+			// escaped newline? (only if we're escaping on slash)
+			if params.escape == '\\' && expEscBreak(in) {
+				// eat escape character and get out (but preserve trailing whitespace!)
+				in.get()
+				lastNonWhitespaceChar = scalar.size()
+				lastEscapedChar = scalar.size()
+				escapedNewline = true
+				break
+			}
+
+			// escape this?
+			if in.peek() == params.escape {
+				scalar.appendString(expEscape(in))
+				lastNonWhitespaceChar = scalar.size()
+				lastEscapedChar = scalar.size()
+				params.endMark = in.mark
+				continue
+			}
+
+			// otherwise, just add the damn character
+			ch := in.peek()
+			scalar.consume()
+			if ch != ' ' && ch != '\t' {
+				lastNonWhitespaceChar = scalar.size()
+				params.endMark = in.mark
+			} else if !params.trimTrailingSpaces {
+				params.endMark = in.mark
+			}
+		}
+
+		// eof? if we're looking to eat something, then we throw
+		if !in.ok() {
+			if params.eatEnd {
+				panic(&ParseError{in.mark, ERR_EOF_IN_SCALAR})
+			}
+			break
+		}
+
+		// doc indicator?
+		if params.onDocIndicator == action_BREAK && in.mark.Column == 0 && (expDocStart(in) || expDocEnd(in)) {
+			break
+		}
+
+		// are we done via character match?
+		if params.end(in) {
+			if params.eatEnd {
+				in.eat(params.endLength)
+				params.endMark = in.mark
+			}
+			break
+		}
+
+		// do we remove trailing whitespace?
+		if params.fold == fold_FLOW {
+			scalar.truncate(lastNonWhitespaceChar)
+		}
+
+		// ********************************
+		// Phase #2: eat line ending
+		in.eat(expBreak(in, 0))
+
+		// ********************************
+		// Phase #3: scan initial spaces
+
+		// first the required indentation
+		for in.peek() == ' ' && (in.mark.Column < params.indent || (params.detectIndent && !foundNonEmptyLine)) && !params.end(in) {
+			in.eat(1)
+		}
+
+		// update indent if we're auto-detecting
+		if params.detectIndent && !foundNonEmptyLine && in.mark.Column > params.indent {
+			params.indent = in.mark.Column
+		}
+
+		// and then the rest of the whitespace
+		for expBlank(in.peek()) {
+			// we check for tabs that masquerade as indentation
+			if in.peek() == '\t' && in.mark.Column < params.indent && params.onTabInIndentation == action_THROW {
+				panic(&ParseError{in.mark, ERR_TAB_IN_INDENTATION})
+			}
+
+			if !params.eatLeadingWhitespace {
+				break
+			}
+
+			if params.end(in) {
+				break
+			}
+
+			in.eat(1)
+		}
+
+		// was this an empty line?
+		nextEmptyLine := expBreak(in, 0) > 0
+		nextMoreIndented := expBlank(in.peek())
+		if params.fold == fold_BLOCK && foldedNewlineCount == 0 && nextEmptyLine {
+			foldedNewlineStartedMoreIndented = moreIndented
+		}
+
+		// for block scalars, we always start with a newline, so we should ignore it (not fold or keep)
+		if pastOpeningBreak {
+			switch params.fold {
+			case fold_DONT:
+				scalar.appendString("\n")
+			case fold_BLOCK:
+				if !emptyLine && !nextEmptyLine && !moreIndented && !nextMoreIndented && in.mark.Column >= params.indent {
+					scalar.appendString(" ")
+				} else if nextEmptyLine {
+					foldedNewlineCount++
+				} else {
+					scalar.appendString("\n")
+				}
+
+				if !nextEmptyLine && foldedNewlineCount > 0 {
+					for i := 1; i < foldedNewlineCount; i++ {
+						scalar.appendString("\n")
+					}
+					if foldedNewlineStartedMoreIndented || nextMoreIndented || !foundNonEmptyLine {
+						scalar.appendString("\n")
+					}
+					foldedNewlineCount = 0
+				}
+			case fold_FLOW:
+				if nextEmptyLine {
+					scalar.appendString("\n")
+				} else if !emptyLine && !escapedNewline {
+					scalar.appendString(" ")
+				}
+			}
+		}
+
+		emptyLine = nextEmptyLine
+		moreIndented = nextMoreIndented
+		pastOpeningBreak = true
+
+		// are we done via indentation?
+		if !emptyLine && in.mark.Column < params.indent {
+			params.leadingSpaces = true
+			break
+		}
+	}
+
+	// post-processing
+	if params.trimTrailingSpaces {
+		pos := scalar.lastNot(' ')
+		if lastEscapedChar >= 0 && pos < lastEscapedChar {
+			pos = lastEscapedChar
+		}
+		scalar.truncate(pos + 1)
+	}
+
+	switch params.chomp {
+	case chomp_CLIP:
+		pos := scalar.lastNot('\n')
+		if lastEscapedChar >= 0 && pos < lastEscapedChar {
+			pos = lastEscapedChar
+		}
+		if pos < 0 {
+			scalar.truncate(0)
+		} else {
+			scalar.truncate(pos + 2)
+		}
+	case chomp_STRIP:
+		pos := scalar.lastNot('\n')
+		if lastEscapedChar >= 0 && pos < lastEscapedChar {
+			pos = lastEscapedChar
+		}
+		scalar.truncate(pos + 1)
+	}
+
+	return scalar.String()
+}
@@ -0,0 +1,542 @@
+package yaml
+
+///////////////////////////////////////////////////////////////////////
+// Specialization for scanning specific tokens
+
+// scanDirective scans a directive, which is %name [param [param ...]].
+func (s *Scanner) scanDirective() {
+	in := s.input
+
+	// pop indents and simple keys
+	s.popAllIndents()
+	s.popAllSimpleKeys()
+
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = false
+
+	// store pos and eat indicator
+	token := s.pushToken(TOKEN_DIRECTIVE)
+	in.eat(1)
+
+	// read name
+	var name []byte
+	for in.ok() && !expBlankOrBreak(in.peek()) {
+		name = append(name, in.get())
+	}
+	token.Value = string(name)
+
+	// read parameters
+	for {
+		// first get rid of whitespace
+		for expBlank(in.peek()) {
+			in.eat(1)
+		}
+
+		// break on newline or comment
+		if !in.ok() || expBreak(in, 0) > 0 || expComment(in) {
+			break
+		}
+
+		// now read parameter
+		var param []byte
+		for in.ok() && !expBlankOrBreak(in.peek()) {
+			param = append(param, in.get())
+		}
+		token.Params = append(token.Params, string(param))
+	}
+	token.EndMark = in.mark
+}
+
+func (s *Scanner) scanDocStart() {
+	s.popAllIndents()
+	s.popAllSimpleKeys()
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = false
+
+	// eat
+	token := s.pushToken(TOKEN_DOC_START)
+	s.input.eat(3)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanDocEnd() {
+	s.popAllIndents()
+	s.popAllSimpleKeys()
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = false
+
+	// eat
+	token := s.pushToken(TOKEN_DOC_END)
+	s.input.eat(3)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanFlowStart() {
+	// flows can be simple keys
+	s.insertPotentialSimpleKey()
+	s.simpleKeyAllowed = true
+	s.canBeJSONFlow = false
+
+	// eat
+	ttype := TOKEN_FLOW_MAP_START
+	flowType := fm_FLOW_MAP
+	if s.input.peek() == key_FLOW_SEQ_START {
+		ttype = TOKEN_FLOW_SEQ_START
+		flowType = fm_FLOW_SEQ
+	}
+	token := s.pushToken(ttype)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+	s.flows = append(s.flows, flowType)
+}
+
+func (s *Scanner) scanFlowEnd() {
+	if s.inBlockContext() {
+		panic(&ParseError{s.input.mark, ERR_FLOW_END})
+	}
+
+	// we might have a solo entry in the flow context
+	s.verifySoloEntry()
+
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = true
+
+	// check that it matches the start
+	ttype := TOKEN_FLOW_MAP_END
+	flowType := fm_FLOW_MAP
+	if s.input.peek() == key_FLOW_SEQ_END {
+		ttype = TOKEN_FLOW_SEQ_END
+		flowType = fm_FLOW_SEQ
+	}
+	if s.flows[len(s.flows)-1] != flowType {
+		panic(&ParseError{s.input.mark, ERR_FLOW_END})
+	}
+	s.flows = s.flows[:len(s.flows)-1]
+
+	// eat
+	token := s.pushToken(ttype)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanFlowEntry() {
+	// we might have a solo entry in the flow context
+	s.verifySoloEntry()
+
+	s.simpleKeyAllowed = true
+	s.canBeJSONFlow = false
+
+	// eat
+	token := s.pushToken(TOKEN_FLOW_ENTRY)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+}
+
+// verifySoloEntry closes a pending simple key in a flow map with an implicit
+// value, or drops it in a flow sequence.
+func (s *Scanner) verifySoloEntry() {
+	if !s.inFlowContext() {
+		return
+	}
+
+	if s.flows[len(s.flows)-1] == fm_FLOW_MAP && s.verifySimpleKey() {
+		s.pushToken(TOKEN_VALUE)
+	} else if s.flows[len(s.flows)-1] == fm_FLOW_SEQ {
+		s.invalidateSimpleKey()
+	}
+}
+
+func (s *Scanner) scanBlockEntry() {
+	// we better be in the block context!
+	if s.inFlowContext() {
+		panic(&ParseError{s.input.mark, ERR_BLOCK_ENTRY})
+	}
+
+	// can we put it here?
+	if !s.simpleKeyAllowed {
+		panic(&ParseError{s.input.mark, ERR_BLOCK_ENTRY})
+	}
+
+	s.pushIndentTo(s.input.mark.Column, it_SEQ)
+	s.simpleKeyAllowed = true
+	s.canBeJSONFlow = false
+
+	// eat
+	token := s.pushToken(TOKEN_BLOCK_ENTRY)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanKey() {
+	// handle keys differently in the block context (and manage indents)
+	if s.inBlockContext() {
+		if !s.simpleKeyAllowed {
+			panic(&ParseError{s.input.mark, ERR_MAP_KEY})
+		}
+
+		s.pushIndentTo(s.input.mark.Column, it_MAP)
+	}
+
+	// can only put a simple key here if we're in block context
+	s.simpleKeyAllowed = s.inBlockContext()
+
+	// eat
+	token := s.pushToken(TOKEN_KEY)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanValue() {
+	// and check that simple key
+	isSimpleKey := s.verifySimpleKey()
+	s.canBeJSONFlow = false
+
+	if isSimpleKey {
+		// can't follow a simple key with another simple key (dunno why, though - it seems fine)
+		s.simpleKeyAllowed = false
+	} else {
+		// handle values differently in the block context (and manage indents)
+		if s.inBlockContext() {
+			if !s.simpleKeyAllowed {
+				panic(&ParseError{s.input.mark, ERR_MAP_VALUE})
+			}
+
+			s.pushIndentTo(s.input.mark.Column, it_MAP)
+		}
+
+		// can only put a simple key here if we're in block context
+		s.simpleKeyAllowed = s.inBlockContext()
+	}
+
+	// eat
+	token := s.pushToken(TOKEN_VALUE)
+	s.input.eat(1)
+	token.EndMark = s.input.mark
+}
+
+func (s *Scanner) scanAnchorOrAlias() {
+	in := s.input
+
+	// insert a potential simple key
+	s.insertPotentialSimpleKey()
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = false
+
+	// eat the indicator
+	mark := in.mark
+	alias := in.get() == key_ALIAS
+
+	// now eat the content
+	var name []byte
+	for in.ok() && expAnchor(in.peek()) {
+		name = append(name, in.get())
+	}
+
+	// we need to have read SOMETHING!
+	if len(name) == 0 {
+		if alias {
+			panic(&ParseError{in.mark, ERR_ALIAS_NOT_FOUND})
+		}
+		panic(&ParseError{in.mark, ERR_ANCHOR_NOT_FOUND})
+	}
+
+	// and needs to end correctly
+	if in.ok() && !expAnchorEnd(in.peek()) {
+		if alias {
+			panic(&ParseError{in.mark, ERR_CHAR_IN_ALIAS})
+		}
+		panic(&ParseError{in.mark, ERR_CHAR_IN_ANCHOR})
+	}
+
+	// and we're done
+	ttype := TOKEN_ANCHOR
+	if alias {
+		ttype = TOKEN_ALIAS
+	}
+	token := s.newToken(ttype, mark)
+	token.Value = string(name)
+	token.EndMark = in.mark
+	s.enqueue(token)
+}
+
+func (s *Scanner) scanTag() {
+	in := s.input
+
+	// insert a potential simple key
+	s.insertPotentialSimpleKey()
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = false
+
+	token := s.newToken(TOKEN_TAG, in.mark)
+
+	// eat the indicator
+	in.get()
+
+	if in.ok() && in.peek() == key_VERBATIM_START {
+		token.Value = scanVerbatimTag(in)
+		token.Data = int(tag_VERBATIM)
+	} else {
+		var canBeHandle bool
+		token.Value = scanTagHandle(in, &canBeHandle)
+		if len(token.Value) == 0 {
+			token.Data = int(tag_NON_SPECIFIC)
+		} else {
+			token.Data = int(tag_PRIMARY_HANDLE)
+		}
+
+		// is there a suffix?
+		if canBeHandle && in.peek() == key_TAG {
+			// eat the indicator
+			in.get()
+			token.Params = append(token.Params, scanTagSuffix(in))
+			token.Data = int(tag_NAMED_HANDLE)
+		}
+	}
+
+	token.EndMark = in.mark
+	s.enqueue(token)
+}
+
+func (s *Scanner) scanPlainScalar() {
+	// set up the scanning parameters
+	params := newScanScalarParams()
+	if s.inFlowContext() {
+		params.end = expScalarEndInFlow
+		params.indent = 0
+	} else {
+		params.end = expScalarEnd
+		params.indent = s.getTopIndent() + 1
+	}
+	params.eatEnd = false
+	params.fold = fold_FLOW
+	params.eatLeadingWhitespace = true
+	params.trimTrailingSpaces = true
+	params.chomp = chomp_STRIP
+	params.onDocIndicator = action_BREAK
+	params.onTabInIndentation = action_THROW
+
+	// insert a potential simple key
+	s.insertPotentialSimpleKey()
+
+	token := s.newToken(TOKEN_PLAIN_SCALAR, s.input.mark)
+	token.Value = scanScalar(s.input, &params)
+	token.EndMark = params.endMark
+
+	// can have a simple key only if we ended the scalar by starting a new line
+	s.simpleKeyAllowed = params.leadingSpaces
+	s.canBeJSONFlow = false
+
+	s.enqueue(token)
+}
+
+func (s *Scanner) scanQuotedScalar() {
+	in := s.input
+
+	// peek at single or double quote (don't eat because we need to preserve (for the time being) the input position)
+	quote := in.peek()
+	single := quote == '\''
+
+	// setup the scanning parameters
+	params := newScanScalarParams()
+	if single {
+		params.end = func(in *stream) bool { return in.at(0) == '\'' && in.at(1) != '\'' }
+	} else {
+		params.end = func(in *stream) bool { return in.at(0) == '"' }
+	}
+	params.endLength = 1
+	params.eatEnd = true
+	params.escape = '\\'
+	if single {
+		params.escape = '\''
+	}
+	params.indent = 0
+	params.fold = fold_FLOW
+	params.eatLeadingWhitespace = true
+	params.trimTrailingSpaces = false
+	params.chomp = chomp_CLIP
+	params.onDocIndicator = action_THROW
+
+	// insert a potential simple key
+	s.insertPotentialSimpleKey()
+
+	token := s.newToken(TOKEN_NON_PLAIN_SCALAR, in.mark)
+
+	// now eat that opening quote
+	in.get()
+
+	// and scan
+	token.Value = scanScalar(in, &params)
+	token.EndMark = params.endMark
+	s.simpleKeyAllowed = false
+	s.canBeJSONFlow = true
+
+	s.enqueue(token)
+}
+
+// scanBlockScalar scans a literal ('|') or folded ('>') block scalar.
+func (s *Scanner) scanBlockScalar() {
+	in := s.input
+
+	params := newScanScalarParams()
+	params.indent = 1
+	params.detectIndent = true
+
+	// eat block indicator ('|' or '>')
+	token := s.newToken(TOKEN_NON_PLAIN_SCALAR, in.mark)
+	if in.get() == key_FOLDED_SCALAR {
+		params.fold = fold_BLOCK
+	} else {
+		params.fold = fold_DONT
+	}
+
+	// eat chomping/indentation indicators
+	params.chomp = chomp_CLIP
+	for i := 0; i < 2; i++ {
+		if ch := in.peek(); ch == '+' {
+			params.chomp = chomp_KEEP
+		} else if ch == '-' {
+			params.chomp = chomp_STRIP
+		} else if expDigit(ch) {
+			if ch == '0' {
+				panic(&ParseError{in.mark, ERR_ZERO_INDENT_IN_BLOCK})
+			}
+
+			params.indent = ch - '0'
+			params.detectIndent = false
+		} else {
+			break
+		}
+		in.eat(1)
+	}
+
+	// now eat whitespace
+	for expBlank(in.peek()) {
+		in.eat(1)
+	}
+
+	// and comments to the end of the line
+	if expComment(in) {
+		for in.ok() && expBreak(in, 0) == 0 {
+			in.eat(1)
+		}
+	}
+
+	// if it's not a line break, then we ran into a bad character inline
+	if in.ok() && expBreak(in, 0) == 0 {
+		panic(&ParseError{in.mark, ERR_CHAR_IN_BLOCK})
+	}
+
+	// set the initial indentation, from the enclosing block; the indent of a
+	// potential simple key (pushed by a tag or anchor before the scalar)
+	// doesn't count, since a block scalar can't be a simple key
+	for i := len(s.indents) - 1; i >= 0; i-- {
+		if indent := s.indents[i]; indent.status != is_UNKNOWN {
+			if indent.column >= 0 {
+				params.indent += indent.column
+			}
+			break
+		}
+	}
+
+	params.eatLeadingWhitespace = false
+	params.trimTrailingSpaces = false
+	params.onTabInIndentation = action_THROW
+
+	token.Value = scanScalar(in, &params)
+	token.EndMark = params.endMark
+
+	// simple keys always ok after block scalars (since we're gonna start a new line anyways)
+	s.simpleKeyAllowed = true
+	s.canBeJSONFlow = false
+
+	s.enqueue(token)
+}
+
+/////////////////////////////////////////////////////////////////////
+// Tag scanning helpers
+
+func scanVerbatimTag(in *stream) string {
+	var tag []byte
+
+	// eat the start character
+	in.get()
+
+	for in.ok() {
+		if in.peek() == key_VERBATIM_END {
+			// eat the end character
+			in.get()
+			return string(tag)
+		}
+
+		n := expURI(in)
+		if n <= 0 {
+			break
+		}
+
+		for ; n > 0; n-- {
+			tag = append(tag, in.get())
+		}
+	}
+
+	panic(&ParseError{in.mark, ERR_END_OF_VERBATIM_TAG})
+}
+
+func scanTagHandle(in *stream, canBeHandle *bool) string {
+	var tag []byte
+	*canBeHandle = true
+	firstNonWordChar := NullMark
+
+	for in.ok() {
+		if in.peek() == key_TAG {
+			if !*canBeHandle {
+				panic(&ParseError{firstNonWordChar, ERR_CHAR_IN_TAG_HANDLE})
+			}
+			break
+		}
+
+		n := 0
+		if *canBeHandle {
+			if expWord(in.peek()) {
+				n = 1
+			} else {
+				*canBeHandle = false
+				firstNonWordChar = in.mark
+			}
+		}
+
+		if !*canBeHandle {
+			n = expTag(in)
+		}
+
+		if n <= 0 {
+			break
+		}
+
+		for ; n > 0; n-- {
+			tag = append(tag, in.get())
+		}
+	}
+
+	return string(tag)
+}
+
+func scanTagSuffix(in *stream) string {
+	var tag []byte
+
+	for in.ok() {
+		n := expTag(in)
+		if n <= 0 {
+			break
+		}
+
+		for ; n > 0; n-- {
+			tag = append(tag, in.get())
+		}
+	}
+
+	if len(tag) == 0 {
+		panic(&ParseError{in.mark, ERR_TAG_WITH_NO_SUFFIX})
+	}
+
+	return string(tag)
+}
@@ -0,0 +1,372 @@
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema, to validate documents against. It
+// supports this subset of draft 2020-12:
+//
+//	type, enum, const
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, minItems, maxItems
+//	pattern, minLength, maxLength
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//	allOf, anyOf, oneOf, not
+//	$ref to "#" or a JSON Pointer into the schema, such as "#/$defs/port"
+//
+// Other keywords are ignored. Patterns are Go regular expressions, which
+// differ from ECMA-262 ones in some details. Values are typed by the core
+// schema (see ResolveTag): "3" is a string and 3 an integer.
+type Schema struct {
+	root     *Node
+	patterns map[*Node]*regexp.Regexp
+	refs     map[*Node]*Node
+}
+
+// ValidationError is a violation of a schema by a node of a document.
+type ValidationError struct {
+	Path    Path
+	Mark    Mark // of the offending node
+	Keyword string
+	Msg     string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v - %v: %v", e.Mark, diffPath(e.Path), e.Msg)
+}
+
+// CompileSchema compiles a schema, as loaded from YAML or JSON, along with
+// every schema its $refs lead to. It fails on a bad pattern or a $ref to
+// nowhere.
+func CompileSchema(schema *Node) (s *Schema, err error) {
+	// Handle compiling panics.
+	defer func() {
+		if r := recover(); r != nil {
+			s = nil
+			var ok bool
+			if err, ok = r.(error); !ok {
+				err = fmt.Errorf("yamlgo: %v", r)
+			}
+		}
+	}()
+
+	s = &Schema{root: schema, patterns: make(map[*Node]*regexp.Regexp), refs: make(map[*Node]*Node)}
+	s.compile(schema, make(map[*Node]bool))
+	return s, nil
+}
+
+func (s *Schema) compile(schema *Node, visited map[*Node]bool) {
+	if schema.Type != NODE_MAP || visited[schema] {
+		return
+	}
+	visited[schema] = true
+
+	for _, pair := range schema.Pairs {
+		value := pair.Value
+		switch pair.Key.Value {
+		case "properties", "$defs", "definitions":
+			for _, property := range value.Pairs {
+				s.compile(property.Value, visited)
+			}
+		case "additionalProperties", "items", "not":
+			s.compile(value, visited)
+		case "allOf", "anyOf", "oneOf":
+			for _, item := range value.Children {
+				s.compile(item, visited)
+			}
+		case "pattern":
+			pattern, err := regexp.Compile(value.Value)
+			if value.Type != NODE_SCALAR || err != nil {
+				panic(&ParseError{value.Mark, ERR_SCHEMA_PATTERN + value.Value})
+			}
+			s.patterns[schema] = pattern
+		case "$ref":
+			// the target may be somewhere the keywords above don't lead
+			target := s.resolveRef(value)
+			s.refs[schema] = target
+			s.compile(target, visited)
+		}
+	}
+}
+
+// resolveRef finds the schema a $ref points to within the schema document.
+func (s *Schema) resolveRef(ref *Node) *Node {
+	pointer, ok := strings.CutPrefix(ref.Value, "#")
+	if ref.Type != NODE_SCALAR || !ok || len(pointer) > 0 && pointer[0] != '/' {
+		panic(&ParseError{ref.Mark, ERR_SCHEMA_REF + ref.Value})
+	}
+
+	node := s.root
+	if len(pointer) > 0 {
+		for _, token := range strings.Split(pointer[1:], "/") {
+			token = pointerUnescaper.Replace(token)
+			if node.Type == NODE_SEQUENCE {
+				i, err := strconv.Atoi(token)
+				node = node.Index(i)
+				if err != nil {
+					node = nil
+				}
+			} else {
+				node = node.Get(token)
+			}
+			if node == nil {
+				panic(&ParseError{ref.Mark, ERR_SCHEMA_REF + ref.Value})
+			}
+		}
+	}
+	return node
+}
+
+// Validate checks doc against the schema and returns every violation found,
+// or nil if doc is valid.
+func (s *Schema) Validate(doc *Node) []*ValidationError {
+	v := &validator{schema: s, active: make(map[[2]*Node]bool)}
+	return v.validate(s.root, doc, Path{})
+}
+
+type validator struct {
+	schema *Schema
+
+	// the schema and node pairs being validated, to stop on cycles
+	active map[[2]*Node]bool
+}
+
+func (v *validator) validate(schema *Node, node *Node, path Path) (errs []*ValidationError) {
+	fail := func(keyword string, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{path, node.Mark, keyword, fmt.Sprintf(format, args...)})
+	}
+
+	// boolean schemas
+	if schema.Type != NODE_MAP {
+		if schema.ResolvedTag() == TAG_BOOL && strings.ToLower(schema.Value) == "false" {
+			fail("false", "no value is allowed here")
+		}
+		return
+	}
+
+	pair := [2]*Node{schema, node}
+	if v.active[pair] {
+		return
+	}
+	v.active[pair] = true
+	defer delete(v.active, pair)
+
+	if target, ok := v.schema.refs[schema]; ok {
+		errs = append(errs, v.validate(target, node, path)...)
+	}
+
+	tag := node.ResolvedTag()
+	num, isNum := schemaNumber(node)
+
+	for _, keyword := range schema.Pairs {
+		value := keyword.Value
+		switch keyword.Key.Value {
+		case "type":
+			types := []*Node{value}
+			if value.Type == NODE_SEQUENCE {
+				types = value.Children
+			}
+
+			ok := false
+			for _, t := range types {
+				ok = ok || schemaHasType(node, tag, t.Value)
+			}
+			if !ok {
+				fail("type", "expected %v, found %v", schemaTypeList(types), schemaTypeName(node, tag))
+			}
+		case "enum":
+			ok := false
+			for _, item := range value.Children {
+				ok = ok || schemaEqual(node, item)
+			}
+			if !ok {
+				fail("enum", "value is not one of the allowed values")
+			}
+		case "const":
+			if !schemaEqual(node, value) {
+				fail("const", "value is not the allowed value")
+			}
+		case "properties":
+			for _, property := range value.Pairs {
+				if child := node.Get(property.Key.Value); child != nil && node.Type == NODE_MAP {
+					errs = append(errs, v.validate(property.Value, child, path.child(PathElement{Key: property.Key.Value}))...)
+				}
+			}
+		case "required":
+			if node.Type != NODE_MAP {
+				break
+			}
+			for _, name := range value.Children {
+				if node.Get(name.Value) == nil {
+					fail("required", "missing required property %q", name.Value)
+				}
+			}
+		case "additionalProperties":
+			if node.Type != NODE_MAP {
+				break
+			}
+			properties := schema.Get("properties")
+			for i, entry := range node.Pairs {
+				if properties != nil && entry.Key.Type == NODE_SCALAR && properties.Get(entry.Key.Value) != nil {
+					continue
+				}
+
+				childPath := path.child(pairPathElement(i, entry))
+				childErrs := v.validate(value, entry.Value, childPath)
+				if value.Type != NODE_MAP && len(childErrs) > 0 {
+					// report a forbidden property at its key
+					childErrs = []*ValidationError{{childPath, entry.Key.Mark, "additionalProperties", fmt.Sprintf("property %q is not allowed", entry.Key.Value)}}
+				}
+				errs = append(errs, childErrs...)
+			}
+		case "minProperties", "maxProperties":
+			if node.Type == NODE_MAP {
+				v.checkBound(fail, keyword.Key.Value, value, float64(len(node.Pairs)), "properties")
+			}
+		case "items":
+			for i, item := range node.Children {
+				errs = append(errs, v.validate(value, item, path.child(PathElement{Index: i, IsIndex: true}))...)
+			}
+		case "minItems", "maxItems":
+			if node.Type == NODE_SEQUENCE {
+				v.checkBound(fail, keyword.Key.Value, value, float64(len(node.Children)), "items")
+			}
+		case "pattern":
+			if tag == TAG_STR && !v.schema.patterns[schema].MatchString(node.Value) {
+				fail("pattern", "%q does not match %q", node.Value, value.Value)
+			}
+		case "minLength", "maxLength":
+			if tag == TAG_STR {
+				v.checkBound(fail, keyword.Key.Value, value, float64(utf8.RuneCountInString(node.Value)), "characters")
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if isNum {
+				v.checkBound(fail, keyword.Key.Value, value, num, "")
+			}
+		case "allOf":
+			for _, sub := range value.Children {
+				errs = append(errs, v.validate(sub, node, path)...)
+			}
+		case "anyOf":
+			ok := false
+			for _, sub := range value.Children {
+				ok = ok || len(v.validate(sub, node, path)) == 0
+			}
+			if !ok {
+				fail("anyOf", "value matches none of the anyOf schemas")
+			}
+		case "oneOf":
+			matches := 0
+			for _, sub := range value.Children {
+				if len(v.validate(sub, node, path)) == 0 {
+					matches++
+				}
+			}
+			if matches != 1 {
+				fail("oneOf", "value matches %d of the oneOf schemas instead of one", matches)
+			}
+		case "not":
+			if len(v.validate(value, node, path)) == 0 {
+				fail("not", "value matches a schema it must not")
+			}
+		}
+	}
+	return
+}
+
+// checkBound checks a count or number against a min or max keyword.
+func (v *validator) checkBound(fail func(string, string, ...interface{}), keyword string, bound *Node, value float64, unit string) {
+	limit, ok := schemaNumber(bound)
+	if !ok {
+		return
+	}
+
+	var failed bool
+	var relation string
+	switch keyword {
+	case "minimum", "minItems", "minLength", "minProperties":
+		failed, relation = value < limit, "at least"
+	case "maximum", "maxItems", "maxLength", "maxProperties":
+		failed, relation = value > limit, "at most"
+	case "exclusiveMinimum":
+		failed, relation = value <= limit, "greater than"
+	case "exclusiveMaximum":
+		failed, relation = value >= limit, "less than"
+	}
+
+	if failed {
+		if len(unit) > 0 {
+			fail(keyword, "expected %v %v %v, found %v", relation, bound.Value, unit, value)
+		} else {
+			fail(keyword, "expected a value %v %v, found %v", relation, bound.Value, value)
+		}
+	}
+}
+
+// schemaNumber returns the value of an integer or float node.
+func schemaNumber(node *Node) (float64, bool) {
+	switch tag := node.ResolvedTag(); tag {
+	case TAG_INT, TAG_FLOAT:
+		f, err := strconv.ParseFloat(normalizeScalar(tag, node.Value), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func schemaHasType(node *Node, tag string, name string) bool {
+	switch name {
+	case "integer":
+		num, ok := schemaNumber(node)
+		return ok && num == math.Trunc(num) && !math.IsInf(num, 0)
+	case "number":
+		_, ok := schemaNumber(node)
+		return ok
+	}
+	return schemaTypeName(node, tag) == name
+}
+
+// schemaTypeName returns the JSON Schema type of a node.
+func schemaTypeName(node *Node, tag string) string {
+	switch node.Type {
+	case NODE_SEQUENCE:
+		return "array"
+	case NODE_MAP:
+		return "object"
+	}
+
+	switch tag {
+	case TAG_NULL:
+		return "null"
+	case TAG_BOOL:
+		return "boolean"
+	case TAG_INT:
+		return "integer"
+	case TAG_FLOAT:
+		return "number"
+	}
+	return "string"
+}
+
+func schemaTypeList(types []*Node) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Value
+	}
+	return strings.Join(names, " or ")
+}
+
+// schemaEqual compares values as JSON Schema does, where 1 and 1.0 are
+// equal.
+func schemaEqual(a, b *Node) bool {
+	if x, ok := schemaNumber(a); ok {
+		y, ok := schemaNumber(b)
+		return ok && x == y
+	}
+	return compareKey(a, make(map[*Node]bool)) == compareKey(b, make(map[*Node]bool))
+}
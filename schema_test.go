@@ -0,0 +1,69 @@
+package yaml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := CompileSchema(mustLoad(t, `type: object
+required: [name, port]
+properties:
+  name: {type: string, pattern: "^[a-z]+$"}
+  port: {$ref: "#/$defs/port"}
+  tags: {type: array, items: {type: string}, maxItems: 2}
+additionalProperties: false
+$defs:
+  port: {type: integer, minimum: 1, maximum: 65535}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		doc    string
+		errors []string
+	}{
+		{"name: web\nport: 0x50\n", nil},
+		{"name: Web\nport: 70000\ntags: [a, 1, c]\nextra: x\n", []string{"name", "port", "tags[1]", "tags", "extra"}},
+		{"port: '80'\n", []string{"$", "port"}},
+	}
+
+	for _, test := range tests {
+		var paths []string
+		for _, e := range schema.Validate(mustLoad(t, test.doc)) {
+			paths = append(paths, diffPath(e.Path))
+		}
+		if strings.Join(paths, " ") != strings.Join(test.errors, " ") {
+			t.Errorf("%q: expected errors at %q, got %q", test.doc, test.errors, paths)
+		}
+	}
+}
+
+// Schemas reached only through a $ref are compiled too.
+func TestSchemaRefTargets(t *testing.T) {
+	schema, err := CompileSchema(mustLoad(t, `$ref: "#/components/name"
+components:
+  name: {type: string, pattern: "^x", not: {$ref: "#/components/long"}}
+  long: {type: string, minLength: 3}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for doc, valid := range map[string]bool{"xy": true, "ab": false, "xyz": false} {
+		if errs := schema.Validate(mustLoad(t, doc)); (len(errs) == 0) != valid {
+			t.Errorf("%q: expected valid %v, got %v", doc, valid, errs)
+		}
+	}
+
+	for _, bad := range []string{
+		"$ref: '#/nowhere'",
+		"$ref: '#/components/a'\ncomponents:\n  a: {$ref: '#/missing'}\n",
+		"$ref: '#/components/a'\ncomponents:\n  a: {pattern: '['}\n",
+		"$ref: 'other.json'",
+	} {
+		if _, err := CompileSchema(mustLoad(t, bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
@@ -54,12 +54,15 @@ func (c *collectionstack) top() collectionType {
 /* Single document parsing code */
 /********************************/
 
+// singleDocParser parses one document. It is reset for each document, which
+// keeps anchors from leaking between documents.
 type singleDocParser struct {
-	scanner    *Scanner
-	directives *Directives
-	cstack     *collectionstack
-	anchors    map[string]Anchor
-	curranchor Anchor
+	scanner     *Scanner
+	directives  *Directives
+	cstack      *collectionstack
+	anchors     map[string]Anchor
+	anchorMarks map[string]Mark
+	curranchor  Anchor
 }
 
 func newSingleDocParser(scanner *Scanner, directives *Directives) *singleDocParser {
@@ -68,10 +71,22 @@ func newSingleDocParser(scanner *Scanner, directives *Directives) *singleDocPars
 		directives: directives,
 		cstack: newCollectionStack(),
 		anchors: make(map[string]Anchor),
+		anchorMarks: make(map[string]Mark),
 		curranchor: NullAnchor,
 	}
 }
 
+// reset readies the parser for the next document, keeping the memory of its
+// stack and maps.
+func (s *singleDocParser) reset(scanner *Scanner, directives *Directives) {
+	s.scanner = scanner
+	s.directives = directives
+	s.cstack.stack = s.cstack.stack[:0]
+	clear(s.anchors)
+	clear(s.anchorMarks)
+	s.curranchor = NullAnchor
+}
+
 func (s *singleDocParser) handleDocument(evtHandler EventHandler) {
 	if s.scanner.Empty() {
 		panic(&ParseError{NullMark, "No tokens in scanner."})
@@ -80,6 +95,9 @@ func (s *singleDocParser) handleDocument(evtHandler EventHandler) {
 	}
 
 	evtHandler.DocumentStart(s.scanner.Peek().Mark)
+	if h, ok := evtHandler.(VersionHandler); ok {
+		h.DocumentVersion(s.scanner.Peek().Mark, s.directives.Version)
+	}
 	
 	// eat doc start
 	if s.scanner.Peek().Type == TOKEN_DOC_START {
@@ -88,6 +106,15 @@ func (s *singleDocParser) handleDocument(evtHandler EventHandler) {
 	
 	// recurse!
 	s.handleNode(evtHandler)
+
+	// the node must take up the whole document
+	if !s.scanner.Empty() {
+		switch token := s.scanner.Peek(); token.Type {
+		case TOKEN_DOC_START, TOKEN_DOC_END, TOKEN_DIRECTIVE:
+		default:
+			panic(&ParseError{token.Mark, ERR_END_OF_DOC})
+		}
+	}
 	evtHandler.DocumentEnd()
 	
 	// and finally eat any doc ends we see
@@ -97,6 +124,8 @@ func (s *singleDocParser) handleDocument(evtHandler EventHandler) {
 }
 
 func (s *singleDocParser) handleNode(evtHandler EventHandler) {
+	s.scanner.checkContext()
+
 	// an empty node *is* a possibility
 	if s.scanner.Empty() {
 		evtHandler.Null(s.scanner.Mark(), NullAnchor)
@@ -120,15 +149,26 @@ func (s *singleDocParser) handleNode(evtHandler EventHandler) {
 		return
 	}
 	
-	tag, anchor := s.parseProperties()
-	token := s.scanner.Peek()
+	tag, anchor, anchorName := s.parseProperties()
+	if anchor != NullAnchor {
+		if h, ok := evtHandler.(AnchorNameHandler); ok {
+			h.AnchorName(mark, anchor, anchorName)
+		}
+	}
 
-	if token.Type == TOKEN_PLAIN_SCALAR && token.Value == "null" {
-		evtHandler.Null(mark, anchor)
-		s.scanner.Pop()
+	// after parsing properties, an empty node is again a possibility
+	if s.scanner.Empty() {
+		if len(tag) == 0 {
+			tag = "?"
+		}
+		s.handleEmptyNode(evtHandler, mark, tag, anchor)
 		return
 	}
-	
+
+	token := s.scanner.Peek()
+
+	// plain scalars keep their spelling, null or not; what they mean is up
+	// to the handler (see ResolveTag)
 	// add non-specific tags
 	if len(tag) == 0 {
 		if token.Type == TOKEN_NON_PLAIN_SCALAR {
@@ -141,7 +181,11 @@ func (s *singleDocParser) handleNode(evtHandler EventHandler) {
 	// now split based on what kind of node we should be
 	switch token.Type {
 		case TOKEN_PLAIN_SCALAR, TOKEN_NON_PLAIN_SCALAR:
-			evtHandler.Scalar(mark, tag, anchor, token.Value)
+			if h, ok := evtHandler.(ScalarBytesHandler); ok {
+				h.ScalarBytes(mark, tag, anchor, token.Bytes())
+			} else {
+				evtHandler.Scalar(mark, tag, anchor, token.Value)
+			}
 			s.scanner.Pop()
 			return
 		case TOKEN_FLOW_SEQ_START, TOKEN_BLOCK_SEQ_START:
@@ -165,8 +209,16 @@ func (s *singleDocParser) handleNode(evtHandler EventHandler) {
 			break
 	}
 	
+	s.handleEmptyNode(evtHandler, mark, tag, anchor)
+}
+
+// handleEmptyNode reports a node with no content: a null, unless it has a
+// tag other than the plain non-specific one.
+func (s *singleDocParser) handleEmptyNode(evtHandler EventHandler, mark Mark, tag string, anchor Anchor) {
 	if tag == "?" {
 		evtHandler.Null(mark, anchor)
+	} else if h, ok := evtHandler.(ScalarBytesHandler); ok {
+		h.ScalarBytes(mark, tag, anchor, nil)
 	} else {
 		evtHandler.Scalar(mark, tag, anchor, "")
 	}
@@ -275,7 +327,8 @@ func (s *singleDocParser) handleBlockMap(evtHandler EventHandler) {
 			panic(&ParseError{s.scanner.Mark(), ERR_END_OF_MAP})
 		}
 		
-		token := s.scanner.Peek()
+		// Make copy; the token is reused once popped.
+		token := *s.scanner.Peek()
 		if token.Type != TOKEN_KEY && token.Type != TOKEN_VALUE && token.Type != TOKEN_BLOCK_MAP_END {
 			panic(&ParseError{token.Mark, ERR_END_OF_MAP})
 		}
@@ -387,13 +440,13 @@ func (s *singleDocParser) handleCompactMapWithNoKey(evtHandler EventHandler) {
 	s.cstack.pop(ct_CompactMap)
 }
 
-func (s *singleDocParser) parseProperties() (tag string, anchor Anchor) {
+func (s *singleDocParser) parseProperties() (tag string, anchor Anchor, anchorName string) {
 	for !s.scanner.Empty() {
 		switch s.scanner.Peek().Type {
 		case TOKEN_TAG:
 			s.parseTag(&tag)
 		case TOKEN_ANCHOR:
-			s.parseAnchor(&anchor)
+			s.parseAnchor(&anchor, &anchorName)
 		default:
 			return
 		}
@@ -412,13 +465,15 @@ func (s *singleDocParser) parseTag(tag *string) {
 	s.scanner.Pop();
 }
 
-func (s *singleDocParser) parseAnchor(anchor *Anchor) {
+func (s *singleDocParser) parseAnchor(anchor *Anchor, anchorName *string) {
 	token := s.scanner.Peek();
 	if *anchor != NullAnchor {
 		panic(&ParseError{token.Mark, ERR_MULTIPLE_ANCHORS})
 	}
 	
 	*anchor = s.registerAnchor(token.Value)
+	*anchorName = token.Value
+	s.anchorMarks[token.Value] = token.Mark
 	s.scanner.Pop();
 }
 
@@ -435,9 +490,49 @@ func (s *singleDocParser) registerAnchor(name string) (ret Anchor) {
 
 func (s *singleDocParser) lookupAnchor(mark Mark, name string) (ret Anchor) {
 	if val, ok := s.anchors[name]; !ok {
-		panic(&ParseError{mark, ERR_UNKNOWN_ANCHOR})
+		err := newAliasError(mark, name)
+		err.Suggestion = s.similarAnchor(name)
+		panic(err)
 	} else {
 		ret = val
 	}
 	return
 }
+
+// similarAnchor returns the defined anchor whose name is closest to name, as
+// long as no more than half of it differs.
+func (s *singleDocParser) similarAnchor(name string) (similar string) {
+	best := -1
+	for candidate := range s.anchors {
+		d := editDistance(name, candidate)
+		if 2*d > len(name) && 2*d > len(candidate) {
+			continue
+		}
+		if best < 0 || d < best || (d == best && candidate < similar) {
+			best, similar = d, candidate
+		}
+	}
+	return
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		diag := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next := min(row[j]+1, row[j-1]+1, diag+cost)
+			diag, row[j] = row[j], next
+		}
+	}
+	return row[len(b)]
+}
@@ -0,0 +1,167 @@
+package yaml
+
+import (
+	"io"
+)
+
+const (
+	// DefaultMaxLookahead is the largest scalar the scanner keeps contiguous
+	// in its read buffer before falling back to copying it. It limits how
+	// much of one scalar is carried over into a new chunk, not the memory the
+	// scanner holds: see NewScannerSize.
+	DefaultMaxLookahead = 64 << 10
+
+	stream_CHUNK_SIZE      = 4096
+	stream_MAX_EMPTY_READS = 100
+)
+
+// stream is the scanner's view of its input. It reads through a chunked
+// buffer: bytes already handed out are never overwritten, so scalars may be
+// sliced straight out of the buffer instead of being copied. A chunk stays
+// alive for as long as any such scalar does.
+type stream struct {
+	reader io.Reader
+	err    error
+
+	buf  []byte // buffered input, buf[off:] is still unread
+	off  int
+	base int // absolute position of buf[0]
+
+	mark Mark
+	prev byte // the byte consumed last, which may be in an earlier chunk
+
+	// yaml11 reads the input as YAML 1.1, where NEL is a line break and \/
+	// isn't an escape
+	yaml11 bool
+
+	// pinned is the scalar currently referencing the buffer; its span is
+	// carried over when a new chunk is allocated, up to maxLookahead bytes.
+	pinned       *scalarBuilder
+	maxLookahead int
+
+	// scalar is reused for each scalar scanned, keeping the memory of its
+	// owned copies
+	scalar scalarBuilder
+}
+
+func newStream(reader io.Reader, maxLookahead int) *stream {
+	if maxLookahead <= 0 {
+		maxLookahead = DefaultMaxLookahead
+	}
+	s := &stream{reader: reader, maxLookahead: maxLookahead}
+
+	// skip a UTF-8 byte order mark without moving the column
+	if s.at(0) == 0xEF && s.at(1) == 0xBB && s.at(2) == 0xBF {
+		s.off += 3
+		s.mark.Pos += 3
+	}
+	return s
+}
+
+// scalarBuilder returns the stream's scalar builder, emptied.
+func (s *stream) scalarBuilder() *scalarBuilder {
+	s.scalar = scalarBuilder{in: s, owned: s.scalar.owned[:0]}
+	s.pinned = nil
+	return &s.scalar
+}
+
+// ok reports whether there is any input left.
+func (s *stream) ok() bool {
+	return s.at(0) >= 0
+}
+
+// at returns the byte i positions ahead, or -1 past the end of the input.
+func (s *stream) at(i int) int {
+	if s.off+i >= len(s.buf) && !s.fill(i) {
+		return -1
+	}
+	return int(s.buf[s.off+i])
+}
+
+func (s *stream) peek() int {
+	return s.at(0)
+}
+
+// get consumes and returns the next byte. The caller must check ok first.
+func (s *stream) get() byte {
+	ch := s.buf[s.off]
+	s.advance(ch)
+	return ch
+}
+
+// eat consumes n bytes.
+func (s *stream) eat(n int) {
+	for ; n > 0 && s.ok(); n-- {
+		s.advance(s.buf[s.off])
+	}
+}
+
+func (s *stream) advance(ch byte) {
+	s.off++
+	s.mark.Pos++
+	if ch == '\n' || ch == 0x85 && s.yaml11 && s.prev == 0xC2 {
+		s.mark.Line++
+		s.mark.Column = 0
+	} else {
+		s.mark.Column++
+	}
+	s.prev = ch
+}
+
+// slice returns the buffered bytes in the absolute range [from, to). The
+// range must still be buffered, which holds for the pinned scalar's span.
+func (s *stream) slice(from, to int) []byte {
+	return s.buf[from-s.base : to-s.base : to-s.base]
+}
+
+// fill reads until at least i+1 unread bytes are buffered.
+func (s *stream) fill(i int) bool {
+	empty := 0
+	for s.off+i >= len(s.buf) {
+		if s.err != nil {
+			if s.err != io.EOF {
+				panic(&ParseError{s.mark, s.err.Error()})
+			}
+			return false
+		}
+
+		if cap(s.buf)-len(s.buf) <= i {
+			s.grow(i)
+		}
+
+		n, err := s.reader.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		s.err = err
+		if n == 0 && err == nil {
+			if empty++; empty >= stream_MAX_EMPTY_READS {
+				s.err = io.ErrNoProgress
+			}
+		}
+	}
+	return true
+}
+
+// grow moves the unread input, and the pinned scalar's span, into a fresh
+// chunk. The old chunk is left untouched for anything still referencing it,
+// and is only freed once nothing does.
+func (s *stream) grow(i int) {
+	keep := s.off
+	if s.pinned != nil {
+		if s.base+s.off-s.pinned.start > s.maxLookahead {
+			s.pinned.spill()
+		} else {
+			keep = s.pinned.start - s.base
+		}
+	}
+
+	size := stream_CHUNK_SIZE
+	for size < 2*(len(s.buf)-keep+i+1) {
+		size *= 2
+	}
+
+	buf := make([]byte, len(s.buf)-keep, size)
+	copy(buf, s.buf[keep:])
+	s.buf = buf
+	s.base += keep
+	s.off -= keep
+}
@@ -1,5 +1,7 @@
 package yaml
 
+import "fmt"
+
 type tagType int
 
 const (
@@ -10,6 +12,21 @@ const (
 	tag_NON_SPECIFIC
 )
 
+var tagTypeNames []string = []string{
+	"VERBATIM",
+	"PRIMARY_HANDLE",
+	"SECONDARY_HANDLE",
+	"NAMED_HANDLE",
+	"NON_SPECIFIC",
+}
+
+func (t tagType) String() string {
+	if t < 0 || int(t) >= len(tagTypeNames) {
+		return fmt.Sprintf("tagType(%d)", int(t))
+	}
+	return tagTypeNames[t]
+}
+
 type tag struct {
 	tagtype tagType
 	handle string
@@ -29,11 +46,14 @@ func tagFromToken(token *Token) tag {
 		case tag_SECONDARY_HANDLE:
 			newTag.value = token.Value
 		case tag_NAMED_HANDLE:
+			if len(token.Params) == 0 {
+				panic(&ParseError{token.Mark, ERR_TAG_WITH_NO_SUFFIX})
+			}
 			newTag.handle = token.Value
 			newTag.value = token.Params[0]
 		case tag_NON_SPECIFIC:
 		default:
-			panic("Bad tag from token.")
+			panic(&ParseError{token.Mark, ERR_INVALID_TAG})
 	}
 
 	return newTag
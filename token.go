@@ -1,6 +1,9 @@
 package yaml
 
-import "fmt"
+import (
+	"fmt"
+	"unsafe"
+)
 
 type TokenType int
 
@@ -61,23 +64,40 @@ const (
 )
 
 type Token struct {
-	Status TokenStatus
-	Type   TokenType
-	Mark   Mark
-	Value  string
-	Params []string
-	Data   int
+	Status  TokenStatus
+	Type    TokenType
+	Mark    Mark
+	EndMark Mark
+	Value   string
+	Params  []string
+	Data    int
 }
 
 func NewToken(ttype TokenType, mark Mark) *Token {
-	return &Token{Status: VALID, Type: ttype, Mark: mark, Params: make([]string, 0, 8)}
+	return &Token{Status: VALID, Type: ttype, Mark: mark, EndMark: mark}
+}
+
+// Bytes returns the token's value without copying it. The slice must not be
+// modified, since it shares memory with Value.
+func (t *Token) Bytes() []byte {
+	if len(t.Value) == 0 {
+		return nil
+	}
+	return unsafe.Slice(unsafe.StringData(t.Value), len(t.Value))
+}
+
+func (t TokenType) String() string {
+	if t < 0 || int(t) >= len(tokenNames) {
+		return fmt.Sprintf("TokenType(%d)", int(t))
+	}
+	return tokenNames[t]
 }
 
 func (t Token) String() (out string) {
-	out = fmt.Sprintf("%v: %v", tokenNames[t.Type], t.Value)
-	//for i, val := range t.Params {
-	//	out << std::string(" ") << token.params[i];
-	//}
+	out = fmt.Sprintf("%v: %v", t.Type, t.Value)
+	for _, param := range t.Params {
+		out += " " + param
+	}
 
 	return
 }
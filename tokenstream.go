@@ -0,0 +1,152 @@
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TokenStream iterates over the tokens the scanner produces for a YAML
+// stream. Use it like a bufio.Scanner:
+//
+//	tokens := NewTokenStream(reader)
+//	for tokens.Next() {
+//		token := tokens.Token()
+//		...
+//	}
+//	if err := tokens.Err(); err != nil {
+//		...
+//	}
+type TokenStream struct {
+	scanner *Scanner
+	token   *Token
+	err     error
+}
+
+func NewTokenStream(reader io.Reader) *TokenStream {
+	return &TokenStream{scanner: NewScanner(reader)}
+}
+
+// Next advances to the next token. It returns false at the end of the stream
+// or on a scanning error.
+func (t *TokenStream) Next() (ok bool) {
+	if t.err != nil || t.scanner == nil {
+		return false
+	}
+
+	// Handle scanning panics.
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			t.token = nil
+			if err, isErr := r.(error); isErr {
+				t.err = err
+			} else {
+				t.err = fmt.Errorf("yamlgo: %v", r)
+			}
+		}
+	}()
+
+	if t.scanner.Empty() {
+		t.token = nil
+		t.scanner = nil
+		return false
+	}
+
+	// Copy the token out; the scanner owns the one in its queue, and reuses
+	// it once popped.
+	token := *t.scanner.Peek()
+	token.Params = append([]string(nil), token.Params...)
+	t.scanner.Pop()
+	t.token = &token
+	return true
+}
+
+// Token returns the token read by the last call to Next.
+func (t *TokenStream) Token() *Token {
+	return t.token
+}
+
+// Err returns the scanning error that stopped the stream, if any.
+func (t *TokenStream) Err() error {
+	return t.err
+}
+
+// DumpTokens writes one line per token of the stream in reader, in the form
+//
+//	line:column-line:column TYPE value [params...] [tag type]
+//
+// with 1-based lines and columns.
+func DumpTokens(writer io.Writer, reader io.Reader) error {
+	tokens := NewTokenStream(reader)
+	for tokens.Next() {
+		token := tokens.Token()
+		line := fmt.Sprintf("%d:%d-%d:%d %v", token.Mark.Line+1, token.Mark.Column+1, token.EndMark.Line+1, token.EndMark.Column+1, token.Type)
+		if len(token.Value) > 0 {
+			line += fmt.Sprintf(" %q", token.Value)
+		}
+		for _, param := range token.Params {
+			line += fmt.Sprintf(" %q", param)
+		}
+		if token.Type == TOKEN_TAG {
+			line += " " + tagType(token.Data).String()
+		}
+
+		if _, err := io.WriteString(writer, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return tokens.Err()
+}
+
+type tokenDump struct {
+	Type    string   `json:"type"`
+	Value   string   `json:"value,omitempty"`
+	Params  []string `json:"params,omitempty"`
+	TagType string   `json:"tagType,omitempty"`
+	Start   markDump `json:"start"`
+	End     markDump `json:"end"`
+}
+
+type markDump struct {
+	Pos    int `json:"pos"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// DumpTokensJSON writes the tokens of the stream in reader as a JSON array
+// with one object per token and line. Marks are 0-based, as in Mark.
+func DumpTokensJSON(writer io.Writer, reader io.Reader) error {
+	if _, err := io.WriteString(writer, "["); err != nil {
+		return err
+	}
+
+	tokens := NewTokenStream(reader)
+	for sep := "\n"; tokens.Next(); sep = ",\n" {
+		token := tokens.Token()
+		dump := tokenDump{
+			Type:   token.Type.String(),
+			Value:  token.Value,
+			Params: token.Params,
+			Start:  markDump(token.Mark),
+			End:    markDump(token.EndMark),
+		}
+		if token.Type == TOKEN_TAG {
+			dump.TagType = tagType(token.Data).String()
+		}
+
+		data, err := json.Marshal(&dump)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(writer, sep+string(data)); err != nil {
+			return err
+		}
+	}
+	if err := tokens.Err(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(writer, "\n]\n")
+	return err
+}
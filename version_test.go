@@ -0,0 +1,151 @@
+package yaml
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// decodeAll decodes every document of text with the decoder setup gives.
+func decodeAll(t *testing.T, text string, setup func(d *Decoder)) ([]*Node, error) {
+	t.Helper()
+	decoder := NewDecoder(strings.NewReader(text))
+	if setup != nil {
+		setup(decoder)
+	}
+
+	var docs []*Node
+	for {
+		doc, err := decoder.Decode()
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string // the values of the first document's items
+	}{
+		{"[yes, 010, on, a\u0085b]", "yes|010|on|a\u0085b"},
+		{"%YAML 1.2\n---\n[yes, 010, on]", "yes|010|on"},
+		{"%YAML 1.1\n---\n[yes, 010, on, 'yes', a\u0085b]", "true|0o10|true|yes|a b"},
+		{"%YAML 1.1\n--- a\n...\n--- [yes]", "a"},
+		{"%YAML 1.3\n---\n[yes, a\u0085b]", "yes|a\u0085b"},
+	}
+
+	for _, test := range tests {
+		docs, err := decodeAll(t, test.text, nil)
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+
+		var values []string
+		if docs[0].IsScalar() {
+			values = append(values, docs[0].Value)
+		}
+		for _, item := range docs[0].Children {
+			values = append(values, item.Value)
+		}
+		if got := strings.Join(values, "|"); got != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.text, test.expected, got)
+		}
+	}
+}
+
+// Documents without directives keep the version of the one before them,
+// while any directives reset it.
+func TestVersionPerDocument(t *testing.T) {
+	const text = "%YAML 1.1\n--- yes\n--- on\n...\n%TAG !x! tag:x,\n--- yes\n"
+	docs, err := decodeAll(t, text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, doc := range docs {
+		values = append(values, doc.Value)
+	}
+	if got := strings.Join(values, "|"); got != "true|true|yes" {
+		t.Errorf("expected true|true|yes, got %v", got)
+	}
+
+	// what follows the directives is scanned under their version, not the
+	// previous document's
+	docs, err = decodeAll(t, "%YAML 1.1\n--- a\n...\n%TAG !x! tag:x,\n---\u0085b\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(docs) != 2 || docs[1].Value != "---\u0085b" {
+		t.Errorf("expected the second document to be read as YAML 1.2, got %v documents", len(docs))
+	}
+}
+
+func TestDefaultVersion(t *testing.T) {
+	docs, err := decodeAll(t, "[yes, 010]\n--- off\n...\n%YAML 1.2\n--- off\n", func(d *Decoder) {
+		d.DefaultVersion = Version{Major: 1, Minor: 1}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []string{docs[0].Children[0].Value, docs[0].Children[1].Value, docs[1].Value, docs[2].Value}
+	if got := strings.Join(values, "|"); got != "true|0o10|false|off" {
+		t.Errorf("expected true|0o10|false|off, got %v", got)
+	}
+}
+
+func TestVersionErrors(t *testing.T) {
+	tests := []struct {
+		text, err string
+	}{
+		{"%YAML 2.0\n--- a\n", ERR_YAML_MAJOR_VERSION},
+		{"%YAML 1.1\n%YAML 1.1\n--- a\n", ERR_REPEATED_YAML_DIRECTIVE},
+		{"%YAML x\n--- a\n", ERR_YAML_VERSION},
+		{"%YAML 1.1\n--- \"\\/\"\n", "unknown escape character"},
+	}
+
+	for _, test := range tests {
+		if _, err := decodeAll(t, test.text, nil); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected an error containing %q, got %v", test.text, test.err, err)
+		}
+	}
+
+	if docs, err := decodeAll(t, "--- \"\\/\"\n", nil); err != nil || docs[0].Value != "/" {
+		t.Errorf("expected \\/ to be an escape in YAML 1.2, got %v", err)
+	}
+}
+
+func TestVersionWarn(t *testing.T) {
+	var warnings []*ParseError
+	_, err := decodeAll(t, "%YAML 1.3\n--- a\n", func(d *Decoder) {
+		d.Warn = func(warning *ParseError) {
+			warnings = append(warnings, warning)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(warnings) != 1 || warnings[0].Message() != ERR_YAML_MINOR_VERSION || warnings[0].Mark().Line != 0 {
+		t.Errorf("expected one warning for the version, got %v", warnings)
+	}
+}
+
+// A NEL is counted as a line break even when the read buffer moves on to a
+// new chunk between its two bytes.
+func TestVersionNELAcrossChunks(t *testing.T) {
+	text := strings.Repeat("x", stream_CHUNK_SIZE-1) + "\u0085a b"
+	in := newStream(iotest.OneByteReader(strings.NewReader(text)), 0)
+	in.yaml11 = true
+
+	in.eat(stream_CHUNK_SIZE)
+	in.at(2) // grows the buffer past the first byte of the NEL
+	in.eat(1)
+	if in.mark.Line != 1 || in.mark.Column != 0 || in.peek() != 'a' {
+		t.Errorf("expected the NEL to end the line, got %+v", in.mark)
+	}
+}